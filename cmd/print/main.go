@@ -1,12 +1,16 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -20,6 +24,49 @@ type itParser interface {
 	Close() error
 }
 
+// Exit codes of cmd/print.
+const (
+	exitOK      = 0 // success
+	exitFailure = 1 // runtime failure: open, seek, parse, write or render
+	exitUsage   = 2 // bad flags or charts-config
+	exitNoData  = 3 // no metrics found in the requested range
+)
+
+// jsonErrors makes fail report errors as a single JSON object on stderr.
+var jsonErrors bool
+
+// timeLayout formats times in item output, -summary, -items-out and CSV metrics, and is tried
+// first when parsing -start/-end (see -time-format).
+var timeLayout = lp.DefaultTimeLayout
+
+// resolveTimeLayout maps a -time-format value to a Go layout: "" or "default" is the LOG
+// layout, "rfc3339" is time.RFC3339Nano, anything else is used as a Go layout verbatim.
+func resolveTimeLayout(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "default", "log":
+		return lp.DefaultTimeLayout
+	case "rfc3339", "iso", "iso8601":
+		return time.RFC3339Nano
+	default:
+		return s
+	}
+}
+
+// fail reports err for the given stage (e.g. "flags", "config", "open", "seek", "parse",
+// "write", "render") on stderr and exits with code.
+func fail(code int, stage string, err error) {
+	if jsonErrors {
+		_ = json.NewEncoder(os.Stderr).Encode(struct {
+			Error string `json:"error"`
+			Stage string `json:"stage"`
+			Code  int    `json:"code"`
+		}{err.Error(), stage, code})
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(code)
+}
+
 type mode int
 
 const (
@@ -27,12 +74,23 @@ const (
 	modeMetrics
 )
 
+// parseTimeFlexible parses an absolute timestamp or a relative expression resolved against time.Now().
 func parseTimeFlexible(s string) (time.Time, error) {
+	return parseTimeFlexibleAt(s, time.Now())
+}
+
+// parseTimeFlexibleAt parses an absolute timestamp, or a relative expression resolved against base
+// such as "now", "-2h", "-30m", "now-90s" or "+15m".
+func parseTimeFlexibleAt(s string, base time.Time) (time.Time, error) {
+	if t, ok, err := parseTimeRelative(s, base); ok {
+		return t, err
+	}
 	formats := []string{
+		timeLayout,
 		"2006/01/02-15:04:05.000000",
 		"2006/01/02-15:04:05",
 		"2006/01/02-15:04",
-		time.RFC3339,
+		time.RFC3339Nano,
 	}
 	var last error
 	for _, f := range formats {
@@ -45,46 +103,27 @@ func parseTimeFlexible(s string) (time.Time, error) {
 	return time.Time{}, last
 }
 
-// computeDerivedExpressions builds common derived series and returns them to be appended:
-// - Compaction_Eff_default: Compaction_Write_GB_default_Sum / (Flush_GB_default_Sum + Add_GB_default_Sum)
-// - Compaction_Eff_data_cf: Compaction_Write_GB_data_cf_Sum / (Flush_GB_data_cf_Sum + Add_GB_data_cf_Sum)
-// - BC_Hit_Ratio:          BC_Hit_Cum_Delta / (BC_Hit_Cum_Delta + BC_Miss_Cum_Delta)
-func computeDerivedExpressions(all []lp.Metric, step time.Duration) []lp.Metric {
-	out := make([]lp.Metric, 0, 256)
-	if step <= 0 {
-		return out
-	}
-	sumAgg := lp.NewBucketAggregator(step, lp.ModeSum)
-	sumAgg.GroupBySource = false
-	sumMetrics := sumAgg.Aggregate(all)
-
-	// Compaction efficiency (default/data_cf) based on SUM bucket metrics
-	if ms, err := lp.ComputeExpression(sumMetrics,
-		"Compaction_Write_GB_default_Sum / (Flush_GB_default_Sum + Add_GB_default_Sum)",
-		"Compaction_Eff_default"); err == nil {
-		out = append(out, ms...)
-	}
-	if ms, err := lp.ComputeExpression(sumMetrics,
-		"Compaction_Write_GB_data_cf_Sum / (Flush_GB_data_cf_Sum + Add_GB_data_cf_Sum)",
-		"Compaction_Eff_data_cf"); err == nil {
-		out = append(out, ms...)
-	}
-
-	// Block Cache Hit Ratio based on DELTA bucket metrics
-	deltaAgg := lp.NewBucketAggregator(step, lp.ModeDelta)
-	deltaAgg.GroupBySource = false
-	deltaMetrics := deltaAgg.Aggregate(all)
-	if ms, err := lp.ComputeExpression(deltaMetrics,
-		"BC_Hit_Cum_Delta / (BC_Hit_Cum_Delta + BC_Miss_Cum_Delta)",
-		"BC_Hit_Ratio"); err == nil {
-		out = append(out, ms...)
+// parseTimeRelative handles "now" and signed Go durations (optionally prefixed by "now").
+// ok is false when s is not a relative expression, so absolute parsing can proceed unchanged.
+func parseTimeRelative(s string, base time.Time) (time.Time, bool, error) {
+	r := strings.ToLower(strings.TrimSpace(s))
+	if r == "now" {
+		return base, true, nil
 	}
-	return out
+	r = strings.TrimPrefix(r, "now")
+	if !strings.HasPrefix(r, "-") && !strings.HasPrefix(r, "+") {
+		return time.Time{}, false, nil
+	}
+	d, err := time.ParseDuration(r)
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("bad relative time %q: %w", s, err)
+	}
+	return base.Add(d), true, nil
 }
 
 func printItem(it lp.LogItem) {
 	fmt.Printf("Type: %s\n", it.Type)
-	fmt.Printf("Time: %s\n", it.StartTime.Format("2006/01/02-15:04:05.000000"))
+	fmt.Printf("Time: %s\n", it.StartTime.Format(timeLayout))
 	fmt.Println("Content:")
 	for _, l := range it.Lines {
 		fmt.Println(l)
@@ -106,6 +145,9 @@ func matchGlob(pattern, path string) bool {
 }
 
 func extraFilepath(pattern string) (fs []string) {
+	if isURL(pattern) {
+		return []string{pattern}
+	}
 	// 匹配当前目录下所有 .txt 文件
 	matches, err := filepath.Glob(pattern)
 	if err != nil {
@@ -117,129 +159,690 @@ func extraFilepath(pattern string) (fs []string) {
 	return
 }
 
+// isURL reports whether a file type path is an http(s) URL rather than a local glob.
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// openLogParser opens a RocksDB LOG from a local path, or streams it forward-only from an
+// http(s) URL; the response body is closed with the parser.
+func openLogParser(path string) (*lp.RocksDLogParser, error) {
+	if !isURL(path) {
+		return lp.NewRocksDLogParser(path)
+	}
+	resp, err := http.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return lp.NewRocksDLogParserFromReader(resp.Body), nil
+}
+
+// openParsers opens the item parser and the matching metric parser for a file type key.
+func openParsers(t, path string) (itParser, lp.MetricParser, error) {
+	switch t {
+	case "LOG":
+		p, err := openLogParser(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, lp.NewRocksDMetricParser(), nil
+	case "SLOWLOG":
+		p, err := lp.NewPikaSlowLogItemParser(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, lp.NewPikaSlowMetricParser(), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown file type %q", t)
+	}
+}
+
+// fileTypeKeys returns the file type keys of typesMap sorted, so files are read in the same
+// order on every run (e.g. which type fills -limit first).
+func fileTypeKeys(typesMap map[string]string) []string {
+	keys := make([]string, 0, len(typesMap))
+	for t := range typesMap {
+		keys = append(keys, t)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printMetricNames lists the distinct metric names (merged across all files) with sample counts.
+func printMetricNames(typesMap map[string]string, start, end time.Time) error {
+	counts := make(map[string]int)
+	for _, t := range fileTypeKeys(typesMap) {
+		f := typesMap[t]
+		for _, path := range extraFilepath(f) {
+			parser, mp, err := openParsers(t, path)
+			if err != nil {
+				return err
+			}
+			names, err := lp.CollectMetricNames(parser, mp, start, end)
+			_ = parser.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			for _, n := range names {
+				counts[n.Name] += n.Count
+			}
+		}
+	}
+	names := make([]string, 0, len(counts))
+	for n := range counts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Printf("%-48s %d\n", n, counts[n])
+	}
+	return nil
+}
+
+// printSummary prints a per-file table of item counts by LogType plus the first/last item time.
+func printSummary(typesMap map[string]string, start, end time.Time) error {
+	types := []lp.LogType{lp.LogTypeDump, lp.LogTypeStatistics, lp.LogTypeEvents, lp.LogTypeSlowLog, lp.LogTypeOther}
+	for _, t := range fileTypeKeys(typesMap) {
+		f := typesMap[t]
+		for _, path := range extraFilepath(f) {
+			parser, _, err := openParsers(t, path)
+			if err != nil {
+				return err
+			}
+			sum, err := lp.SummarizeItems(parser, start, end)
+			_ = parser.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			fmt.Printf("File: %s (%s)\n", path, t)
+			for _, lt := range types {
+				if c := sum.Counts[lt]; c > 0 {
+					fmt.Printf("  %-12s %d\n", lt, c)
+				}
+			}
+			fmt.Printf("  %-12s %d\n", "TOTAL", sum.Total)
+			if sum.Total > 0 {
+				fmt.Printf("  First: %s\n", sum.First.Format(timeLayout))
+				fmt.Printf("  Last:  %s\n", sum.Last.Format(timeLayout))
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+// itemRate counts items per bucket across all configured files as a single LogItems_Count series.
+func itemRate(typesMap map[string]string, start, end time.Time, step time.Duration) ([]lp.Metric, error) {
+	var all []lp.Metric
+	for _, t := range fileTypeKeys(typesMap) {
+		f := typesMap[t]
+		for _, path := range extraFilepath(f) {
+			parser, _, err := openParsers(t, path)
+			if err != nil {
+				return nil, err
+			}
+			ms, err := lp.ItemRate(parser, start, end, step)
+			_ = parser.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			all = append(all, ms...)
+		}
+	}
+	// merge per-file counts that share a bucket
+	agg := lp.NewBucketAggregator(step, lp.ModeSum)
+	agg.GroupBySource = false
+	merged := agg.Aggregate(all)
+	for i := range merged {
+		merged[i].Name = "LogItems_Count"
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].StartTime.Before(merged[j].StartTime) })
+	return merged, nil
+}
+
+// writeItems writes the items in [start, end] of all configured files to path as JSON lines,
+// one file at a time, and returns how many were written; limit > 0 stops after that many.
+func writeItems(typesMap map[string]string, start, end time.Time, path string, limit int) (int, error) {
+	out := os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := lp.NewItems2JSON()
+	w.TimeFormat = timeLayout
+	n := 0
+	for _, t := range fileTypeKeys(typesMap) {
+		f := typesMap[t]
+		for _, p := range extraFilepath(f) {
+			parser, _, err := openParsers(t, p)
+			if err != nil {
+				return n, err
+			}
+			items, err := lp.CollectItemsLimit(parser, start, end, limit-n)
+			_ = parser.Close()
+			if err != nil {
+				return n, fmt.Errorf("%s: %w", p, err)
+			}
+			if err := w.Write(items, out); err != nil {
+				return n, err
+			}
+			n += len(items)
+			if limit > 0 && n >= limit {
+				return n, nil
+			}
+		}
+	}
+	return n, nil
+}
+
+// printPlan prints what each chart group would render and warns about empty groups on stderr.
+func printPlan(plans []lp.GroupPlan, single string) {
+	if single != "" {
+		fmt.Printf("All groups -> %s\n", single)
+	}
+	for i, p := range plans {
+		fmt.Printf("Group %d: %s", i, p.Out)
+		if p.Title != "" {
+			fmt.Printf(" (%s)", p.Title)
+		}
+		fmt.Printf(": %d series, %d points\n", len(p.Series), p.Points)
+		for _, n := range p.Series {
+			fmt.Printf("  %s\n", n)
+		}
+		if len(p.Series) == 0 {
+			fmt.Fprintf(os.Stderr, "warning: group %d (%s) matched no series\n", i, p.Out)
+		}
+	}
+}
+
+// loadChartsConfig reads the charts config named by v: "-" reads stdin, a value starting with
+// '{' or '[' is inline JSON, and anything else is a file path. With strict, unknown fields are
+// reported as errors instead of being ignored.
+func loadChartsConfig(v string, strict bool) (lp.ChartsConfigFull, error) {
+	trimmed := strings.TrimSpace(v)
+	var data []byte
+	var err error
+	isPath := false
+	switch {
+	case trimmed == "-":
+		data, err = io.ReadAll(os.Stdin)
+	case strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "["):
+		data = []byte(trimmed)
+	default:
+		data, err = os.ReadFile(v)
+		isPath = true
+	}
+	if err != nil {
+		return lp.ChartsConfigFull{}, err
+	}
+	if strict {
+		if err := lp.ValidateChartsConfig(data); err != nil {
+			return lp.ChartsConfigFull{}, err
+		}
+	}
+	if isPath {
+		// resolves includes relative to the config file
+		return lp.LoadChartsConfig(v)
+	}
+	return lp.LoadChartsConfigFromBytes(data)
+}
+
+// writeMetrics writes metrics to path in the requested format ("csv", "csv-split", "prom" or "influx").
+func writeMetrics(metrics []lp.Metric, path, format string, dropZero bool) error {
+	if dropZero {
+		metrics = lp.DropZeroSeries(metrics)
+	}
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "csv":
+		if path == "-" {
+			path = "/dev/stdout"
+		}
+		w := lp.NewMetric2CSV()
+		w.TimeFormat = timeLayout
+		return w.WriteFile(metrics, path)
+	case "csv-split":
+		// path is a directory receiving one <name>.csv per metric
+		w := lp.NewMetric2CSV()
+		w.TimeFormat = timeLayout
+		return w.WriteSplit(metrics, path)
+	case "prom", "prometheus":
+		return lp.NewMetric2Prom().WriteFile(metrics, path)
+	case "influx", "influxdb":
+		return lp.NewMetric2Influx().WriteFile(metrics, path)
+	default:
+		return fmt.Errorf("unknown metrics format %q", format)
+	}
+}
+
 func main() {
 	var startStr, endStr string
 	var chartsConfig string
+	var chartsArg string
 	var chartsOutOne string
-	flag.StringVar(&startStr, "start", "", "start time (e.g., 2025/11/30-03:16:58.152255)")
-	flag.StringVar(&endStr, "end", "", "end time (e.g., 2025/11/30-08:23)")
-	flag.StringVar(&chartsConfig, "charts-config", "", "load chart groups from JSON (raw array or {\"groups\": [...]})")
+	var listMetrics bool
+	var summary bool
+	var metricsOut, metricsFormat string
+	var cfFilter string
+	var dropZero bool
+	var strictConfig bool
+	var dedupe string
+	var skipTypes string
+	var itemRateOut string
+	var dryRun bool
+	var streamMetrics bool
+	var mergeCF bool
+	var ignoreCase bool
+	var showMissing bool
+	var showProgress bool
+	var noDerived bool
+	var limit int
+	var maxItemLines int
+	var itemsOut string
+	var includeNames, excludeNames string
+	var timeFormat string
+	flag.StringVar(&startStr, "start", "", "start time (e.g., 2025/11/30-03:16:58.152255, -1h relative to -end or now); empty reads from the beginning")
+	flag.StringVar(&endStr, "end", "", "end time (e.g., 2025/11/30-08:23, now, -30m); empty reads to EOF")
+	flag.StringVar(&chartsConfig, "charts-config", "", "load chart groups from JSON (raw array or {\"groups\": [...]}); \"-\" reads stdin, a value starting with { or [ is inline JSON")
+	flag.StringVar(&chartsArg, "charts", "", "chart groups replacing those of -charts-config: a JSON array ([{\"out\":...,\"names\":[...]}]) or \"out.svg:Title:Name1,Name2; ...\"; names may be globs (Level*_Size_MB_Sum) expanded into one line per matching series")
 	flag.StringVar(&chartsOutOne, "charts-out-one", "", "if set, compose all -charts groups into a single stacked SVG output")
+	flag.BoolVar(&listMetrics, "list-metrics", false, "list distinct metric names (with sample counts) found in the configured files and exit")
+	flag.BoolVar(&summary, "summary", false, "print item counts per type and the time span of the configured files and exit")
+	flag.StringVar(&metricsOut, "metrics-out", "", "write the parsed (plus config-derived, unless -no-derived) metrics, before per-group bucket aggregation, to this path (\"-\" for stdout)")
+	flag.StringVar(&metricsFormat, "metrics-format", "csv", "format for -metrics-out: csv, csv-split (-metrics-out is a directory), prom or influx")
+	flag.BoolVar(&streamMetrics, "stream-metrics", false, "append metrics to -metrics-out (csv) item by item instead of holding the whole range in memory, then exit without rendering charts")
+	flag.StringVar(&cfFilter, "cf", "", "keep only metrics of this column family (e.g. data_cf); empty keeps all")
+	flag.StringVar(&includeNames, "include-names", "", "comma-separated metric names or globs to keep while parsing (e.g. Flush_GB*,BC_*); empty keeps all")
+	flag.StringVar(&excludeNames, "exclude-names", "", "comma-separated metric names or globs to drop while parsing")
+	flag.StringVar(&itemsOut, "items-out", "", "write the classified log items as JSON lines ({\"time\",\"type\",\"lines\"}) to this path (\"-\" for stdout) and exit without parsing metrics")
+	flag.IntVar(&maxItemLines, "max-item-lines", 0, "cap the continuation lines per log item (guards against malformed logs); 0 is unlimited")
+	flag.IntVar(&limit, "limit", 0, "stop after N items (-items-out) or N parsed metric rows (after -cf), then write and render what was collected; file types are read in name order (LOG before SLOWLOG); 0 is unlimited")
+	flag.BoolVar(&showProgress, "progress", false, "report bytes read and items parsed per file on stderr while scanning")
+	flag.BoolVar(&showMissing, "show-missing", false, "list chart group names that matched no metrics in the chart legend as \"(no data)\"")
+	flag.BoolVar(&ignoreCase, "ignore-case", false, "match chart group names case-insensitively (globs always are)")
+	flag.BoolVar(&mergeCF, "merge-cf", false, "sum per-CF metrics into instance totals (Flush_GB_default + Flush_GB_data_cf -> Flush_GB) for metrics, CSV and charts")
+	flag.BoolVar(&dropZero, "drop-zero", false, "skip series whose every value is 0 in charts and -metrics-out")
+	flag.BoolVar(&noDerived, "no-derived", false, "skip the charts-config \"derived\" sets (e.g. read_amp) instead of adding them to the metrics and charts")
+	flag.BoolVar(&dryRun, "dry-run", false, "parse and select metrics, then print per chart group the output path and matched series instead of rendering")
+	flag.StringVar(&itemRateOut, "item-rate", "", "render items per bucket (head times only, no metric parsing) to this SVG and exit; honours -metrics-out")
+	flag.StringVar(&skipTypes, "skip-types", "", "comma-separated LOG item types to skip while parsing (e.g. OTHER,EVENTS)")
+	flag.StringVar(&dedupe, "dedupe", "", "drop duplicate metrics across items: exact (same time, name, source and value) or name (first per time and name)")
+	flag.BoolVar(&strictConfig, "strict-config", false, "reject unknown fields in -charts-config (e.g. \"name\" for \"names\")")
+	flag.StringVar(&timeFormat, "time-format", "default", "timestamp layout for printed items, -summary, -items-out and CSV metrics, also accepted by -start/-end: default (2006/01/02-15:04:05.000000), rfc3339 or a Go layout")
+	flag.BoolVar(&jsonErrors, "json-errors", false, "report errors as a JSON object {\"error\",\"stage\",\"code\"} on stderr; exit codes: 1 failure, 2 usage, 3 no data")
 	flag.Parse()
+	timeLayout = resolveTimeLayout(timeFormat)
 
-	if startStr == "" || endStr == "" {
-		fmt.Fprintln(os.Stderr, "missing -start or -end")
-		os.Exit(2)
+	// Empty -start means the beginning of each file; empty -end means read to EOF.
+	// Relative values resolve against now; a relative -start resolves against -end when set.
+	var start, end time.Time
+	var err error
+	if endStr != "" {
+		end, err = parseTimeFlexible(endStr)
+		if err != nil {
+			fail(exitUsage, "flags", fmt.Errorf("bad -end: %w", err))
+		}
 	}
-	start, err := parseTimeFlexible(startStr)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "bad -start:", err)
-		os.Exit(2)
+	if startStr != "" {
+		base := time.Now()
+		if !end.IsZero() {
+			base = end
+		}
+		start, err = parseTimeFlexibleAt(startStr, base)
+		if err != nil {
+			fail(exitUsage, "flags", fmt.Errorf("bad -start: %w", err))
+		}
 	}
-	end, err := parseTimeFlexible(endStr)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "bad -end:", err)
-		os.Exit(2)
+
+	dedupe = strings.ToLower(strings.TrimSpace(dedupe))
+	if dedupe != "" && dedupe != "exact" && dedupe != "name" {
+		fail(exitUsage, "flags", fmt.Errorf("bad -dedupe: %q (want exact or name)", dedupe))
 	}
 
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "bad -bucket:", err)
-		os.Exit(2)
+	if streamMetrics {
+		if metricsOut == "" {
+			fail(exitUsage, "flags", errors.New("bad -stream-metrics: requires -metrics-out"))
+		}
+		if f := strings.ToLower(strings.TrimSpace(metricsFormat)); f != "" && f != "csv" {
+			fail(exitUsage, "flags", fmt.Errorf("bad -stream-metrics: -metrics-format %q (want csv)", metricsFormat))
+		}
+		if dedupe != "" || dropZero {
+			fail(exitUsage, "flags", errors.New("bad -stream-metrics: -dedupe and -drop-zero need the whole range"))
+		}
+	}
+
+	var skip []lp.LogType
+	for _, t := range strings.Split(skipTypes, ",") {
+		if t = strings.ToUpper(strings.TrimSpace(t)); t != "" {
+			skip = append(skip, lp.LogType(t))
+		}
 	}
 
 	if chartsConfig == "" {
-		fmt.Fprintln(os.Stderr, "bad -charts-config:")
-		os.Exit(2)
+		fail(exitUsage, "flags", errors.New("bad -charts-config: required"))
 	}
 
-	groups, typesMap, bucketCfg, err := lp.ParseChartsConfigFull(chartsConfig)
+	cfg, err := loadChartsConfig(chartsConfig, strictConfig)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "bad -charts-config:", err)
-		os.Exit(2)
+		fail(exitUsage, "config", fmt.Errorf("bad -charts-config: %w", err))
+	}
+	groups, typesMap, bucketCfg := cfg.Groups, cfg.FileTypes, cfg.Bucket
+	if strings.TrimSpace(chartsArg) != "" {
+		groups, err = lp.ParseChartsArg(chartsArg)
+		if err != nil {
+			fail(exitUsage, "config", fmt.Errorf("bad -charts: %w", err))
+		}
 	}
 
+	// Prefer config options over CLI when using charts-config
+	bucketStep := 10 * time.Minute
+	if strings.TrimSpace(bucketCfg) != "" {
+		if d, e := time.ParseDuration(strings.TrimSpace(bucketCfg)); e == nil {
+			bucketStep = d
+		}
+	}
+
+	if summary {
+		if err := printSummary(typesMap, start, end); err != nil {
+			fail(exitFailure, "parse", fmt.Errorf("summary: %w", err))
+		}
+		return
+	}
+
+	if itemsOut != "" {
+		n, err := writeItems(typesMap, start, end, itemsOut, limit)
+		if err != nil {
+			fail(exitFailure, "write", fmt.Errorf("write items: %w", err))
+		}
+		if n == 0 {
+			fail(exitNoData, "collect", errors.New("no items in the requested range"))
+		}
+		return
+	}
+
+	if itemRateOut != "" {
+		ms, err := itemRate(typesMap, start, end, bucketStep)
+		if err != nil {
+			fail(exitFailure, "parse", fmt.Errorf("item rate: %w", err))
+		}
+		if len(ms) == 0 {
+			fail(exitNoData, "collect", errors.New("no items in the requested range"))
+		}
+		if metricsOut != "" {
+			if err := writeMetrics(ms, metricsOut, metricsFormat, false); err != nil {
+				fail(exitFailure, "write", fmt.Errorf("write metrics: %w", err))
+			}
+		}
+		dlg := lp.NewDialog()
+		dlg.Title = fmt.Sprintf("Log items per %s", bucketStep)
+		if err := dlg.Render(ms, itemRateOut); err != nil {
+			fail(exitFailure, "render", fmt.Errorf("render item rate: %w", err))
+		}
+		return
+	}
+
+	if listMetrics {
+		if err := printMetricNames(typesMap, start, end); err != nil {
+			fail(exitFailure, "parse", fmt.Errorf("list metrics: %w", err))
+		}
+		return
+	}
+
+	// emit collects parsed metrics; with -stream-metrics each batch is appended to -metrics-out
+	// right away instead.
 	var allMetrics []lp.Metric
-	for t, f := range typesMap {
+	var stream *lp.Metric2CSV
+	streamed := 0
+	if streamMetrics {
+		path := metricsOut
+		if path == "-" {
+			path = "/dev/stdout"
+		}
+		if len(cfg.Derived) > 0 && !noDerived {
+			fmt.Fprintln(os.Stderr, "warning: -stream-metrics writes raw batches; the config's derived sets are not added")
+		}
+		stream = lp.NewMetric2CSV()
+		stream.TimeFormat = timeLayout
+		if err := stream.Open(path); err != nil {
+			fail(exitFailure, "write", fmt.Errorf("write metrics: %w", err))
+		}
+	}
+	// collected counts the rows emit accepted, for -limit.
+	collected := 0
+	limitHit := func() bool { return limit > 0 && collected >= limit }
+	emit := func(ms []lp.Metric) {
+		ms = lp.FilterByCF(ms, cfFilter)
+		if limit > 0 && len(ms) > limit-collected {
+			ms = ms[:limit-collected]
+		}
+		collected += len(ms)
+		if stream == nil {
+			allMetrics = append(allMetrics, ms...)
+			return
+		}
+		if mergeCF {
+			ms = lp.MergeCF(ms)
+		}
+		if len(ms) == 0 {
+			return
+		}
+		if err := stream.Write(ms); err != nil {
+			fail(exitFailure, "write", fmt.Errorf("write metrics: %w", err))
+		}
+		streamed += len(ms)
+	}
+	for _, t := range fileTypeKeys(typesMap) {
+		f := typesMap[t]
 		switch t {
 		case "LOG":
 			mp := lp.NewRocksDMetricParser()
+			mp.IncludeNames, mp.ExcludeNames = splitList(includeNames), splitList(excludeNames)
 			ps := extraFilepath(f)
+			// A DUMP split by log rotation: the DUMPING STATS head ends one file and the
+			// DB Stats half starts the next. Hold the dangling head until the next file is read.
+			var pending *lp.LogItem
 			for _, p := range ps {
-				parser, err := lp.NewRocksDLogParser(p)
+				parser, err := openLogParser(p)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "cannot open filepath:%s err:%s", p, err.Error())
-					os.Exit(2)
+					fail(exitFailure, "open", fmt.Errorf("cannot open filepath:%s err:%w", p, err))
 				}
+				parser.SkipTypes = skip
+				parser.MaxItemLines = maxItemLines
+				var progressDone func()
+				parser.OnProgress, progressDone = progressReporter(p, showProgress)
 				err = parser.Seek(start)
-				if err == io.EOF {
+				if err != nil {
+					progressDone()
+				}
+				if errors.Is(err, lp.ErrEOF) {
 					_ = parser.Close()
 					continue
+				} else if err != nil {
+					fail(exitFailure, "seek", fmt.Errorf("seek %s: %w", p, err))
 				}
 				for {
 					i, err := parser.Value()
-					if err == io.EOF || i.StartTime.After(end) {
+					if errors.Is(err, lp.ErrNoItem) || (!end.IsZero() && i.StartTime.After(end)) {
+						break
+					}
+					if pending != nil {
+						if merged, ok := lp.JoinSplitDump(*pending, i); ok {
+							i = merged
+						} else {
+							emit(mp.Parse(*pending))
+						}
+						pending = nil
+					}
+					more := parser.Next()
+					if !more && lp.IsDanglingDump(i) {
+						pending = &i
 						break
 					}
-					allMetrics = append(allMetrics, mp.Parse(i)...)
-					ok := parser.Next()
-					if !ok {
+					emit(mp.Parse(i))
+					if !more || limitHit() {
 						break
 					}
 				}
 				_ = parser.Close()
+				progressDone()
+				warnTruncated(p, parser.Truncated, maxItemLines)
+				if limitHit() {
+					break
+				}
+			}
+			if pending != nil && !limitHit() {
+				emit(mp.Parse(*pending))
 			}
 		case "SLOWLOG":
 			mp := lp.NewPikaSlowMetricParser()
+			mp.IncludeNames, mp.ExcludeNames = splitList(includeNames), splitList(excludeNames)
 			ps := extraFilepath(f)
 			for _, p := range ps {
 				parser, err := lp.NewPikaSlowLogItemParser(p)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "cannot open filepath:%s err:%s", p, err.Error())
-					os.Exit(2)
+					fail(exitFailure, "open", fmt.Errorf("cannot open filepath:%s err:%w", p, err))
 				}
-				err = parser.Seek(start)
-				if err == io.EOF {
-					_ = parser.Close()
-					continue
-				}
-				for {
-					i, err := parser.Value()
-					if err == io.EOF || i.StartTime.After(end) {
-						break
-					}
-					allMetrics = append(allMetrics, mp.Parse(i)...)
-					ok := parser.Next()
-					if !ok {
-						break
-					}
+				parser.MaxItemLines = maxItemLines
+				var progressDone func()
+				parser.OnProgress, progressDone = progressReporter(p, showProgress)
+				it := lp.NewMetricIterator(parser, mp, start, end)
+				for !limitHit() && it.Next() {
+					emit(it.Metrics())
 				}
 				_ = parser.Close()
+				progressDone()
+				warnTruncated(p, parser.Truncated, maxItemLines)
+				if err := it.Err(); err != nil {
+					fail(exitFailure, "parse", fmt.Errorf("parse %s: %w", p, err))
+				}
+				if limitHit() {
+					break
+				}
 			}
 		}
+		if limitHit() {
+			break
+		}
 	}
 
-	// Prefer config options over CLI when using charts-config
-	bucketStep := 10 * time.Minute
-	if strings.TrimSpace(bucketCfg) != "" {
-		if d, e := time.ParseDuration(strings.TrimSpace(bucketCfg)); e == nil {
-			bucketStep = d
+	if stream != nil {
+		if err := stream.Close(); err != nil {
+			fail(exitFailure, "write", fmt.Errorf("write metrics: %w", err))
+		}
+		if streamed == 0 {
+			fail(exitNoData, "collect", errors.New("no metrics in the requested range"))
+		}
+		return
+	}
+
+	// emit already applied -cf to every batch
+	if mergeCF {
+		allMetrics = lp.MergeCF(allMetrics)
+	}
+	switch dedupe {
+	case "exact":
+		allMetrics = lp.DedupeMetrics(allMetrics)
+	case "name":
+		allMetrics = lp.DedupeMetricsByName(allMetrics)
+	}
+	// The config's "derived" sets are built from the raw metrics, ahead of any bucketing.
+	if len(cfg.Derived) > 0 && !noDerived {
+		ms, err := lp.DeriveStandard(allMetrics, cfg.Derived)
+		if err != nil {
+			fail(exitUsage, "config", fmt.Errorf("bad -charts-config: %w", err))
 		}
+		allMetrics = append(allMetrics, ms...)
 	}
 	// Ignore CLI -agg; per-group agg from config is used. Default fallback is SUM only if a group omits agg.
 	defaultMode := lp.ModeSum
 
+	if dryRun {
+		orch := lp.NewChartOrchestrator(groups)
+		orch.DropZeroSeries = dropZero
+		orch.CaseInsensitiveNames = ignoreCase
+		orch.ShowMissing = showMissing
+		printPlan(orch.PlanWithAgg(allMetrics, bucketStep, defaultMode, false), chartsOutOne)
+		return
+	}
+
+	if len(allMetrics) == 0 {
+		fail(exitNoData, "collect", errors.New("no metrics in the requested range"))
+	}
+
+	if metricsOut != "" {
+		if err := writeMetrics(allMetrics, metricsOut, metricsFormat, dropZero); err != nil {
+			fail(exitFailure, "write", fmt.Errorf("write metrics: %w", err))
+		}
+	}
+
 	// Optional chart from raw metrics
 	if chartsConfig != "" && chartsOutOne != "" {
-		orch := lp.ChartOrchestrator{Groups: groups}
+		orch := lp.NewChartOrchestrator(groups)
+		orch.DropZeroSeries = dropZero
+		orch.CaseInsensitiveNames = ignoreCase
+		orch.ShowMissing = showMissing
 		if err := orch.RenderAllSingleWithAgg(allMetrics, chartsOutOne, bucketStep, defaultMode, false); err != nil {
-			fmt.Fprintln(os.Stderr, "render charts (single):", err)
-			os.Exit(1)
+			fail(exitFailure, "render", fmt.Errorf("render charts (single): %w", err))
 		}
+		warnSkipped(orch.Skipped)
 	} else if chartsConfig != "" {
-		orch := lp.ChartOrchestrator{Groups: groups}
+		orch := lp.NewChartOrchestrator(groups)
+		orch.DropZeroSeries = dropZero
+		orch.CaseInsensitiveNames = ignoreCase
+		orch.ShowMissing = showMissing
 		if err := orch.RenderAllWithAgg(allMetrics, bucketStep, defaultMode, false); err != nil {
-			fmt.Fprintln(os.Stderr, "render charts:", err)
-			os.Exit(1)
+			fail(exitFailure, "render", fmt.Errorf("render charts: %w", err))
+		}
+		warnSkipped(orch.Skipped)
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// warnTruncated reports items of path cut at -max-item-lines.
+func warnTruncated(path string, n, max int) {
+	if n > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %s: %d item(s) truncated at %d lines\n", path, n, max)
+	}
+}
+
+// progressReporter returns an OnProgress hook that rewrites one stderr status line for path,
+// and a done func ending that line; both are no-ops when enabled is false.
+func progressReporter(path string, enabled bool) (func(int64, int), func()) {
+	if !enabled {
+		return nil, func() {}
+	}
+	shown := false
+	report := func(bytesRead int64, items int) {
+		shown = true
+		fmt.Fprintf(os.Stderr, "\r%s: %.1f MB read, %d items", path, float64(bytesRead)/(1024*1024), items)
+	}
+	return report, func() {
+		if shown {
+			fmt.Fprintln(os.Stderr)
 		}
 	}
 }
+
+// warnSkipped reports chart groups skipped for selecting no metrics.
+func warnSkipped(skipped []string) {
+	for _, g := range skipped {
+		fmt.Fprintf(os.Stderr, "warning: chart group %s matched no metrics; skipped\n", g)
+	}
+}
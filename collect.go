@@ -0,0 +1,241 @@
+package logparser
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ItemParser is the iteration contract shared by RocksDLogParser and PikaSlowLogItemParser.
+type ItemParser interface {
+	Seek(time.Time) error
+	Next() bool
+	Value() (LogItem, error)
+	Close() error
+}
+
+// MetricParser extracts metrics from one LogItem (RocksDMetricParser, PikaSlowMetricParser).
+type MetricParser interface {
+	Parse(LogItem) []Metric
+}
+
+// MetricNameCount is one distinct metric name and the number of samples seen for it.
+type MetricNameCount struct {
+	Name  string
+	Count int
+}
+
+// CollectMetricNames iterates items in [start, end], parses their metrics and returns the
+// distinct metric names sorted by name, with sample counts. A zero end means no upper bound.
+// The caller owns the parser and is responsible for closing it.
+func CollectMetricNames(p ItemParser, mp MetricParser, start, end time.Time) ([]MetricNameCount, error) {
+	counts := make(map[string]int)
+	it := NewMetricIterator(p, mp, start, end)
+	for it.Next() {
+		for _, m := range it.Metrics() {
+			counts[m.Name]++
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]MetricNameCount, 0, len(counts))
+	for n, c := range counts {
+		out = append(out, MetricNameCount{Name: n, Count: c})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// CollectItems returns all items in [start, end]; zero start/end mean no bound. An empty
+// range (Seek past the last item) yields no items and no error. The caller owns the parser
+// and is responsible for closing it.
+func CollectItems(p ItemParser, start, end time.Time) ([]LogItem, error) {
+	return CollectItemsLimit(p, start, end, 0)
+}
+
+// CollectItemsLimit is CollectItems stopping after the first limit items; limit <= 0 means
+// no limit.
+func CollectItemsLimit(p ItemParser, start, end time.Time, limit int) ([]LogItem, error) {
+	var out []LogItem
+	if err := p.Seek(start); err != nil {
+		if errors.Is(err, ErrEOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	for {
+		it, err := p.Value()
+		if errors.Is(err, ErrNoItem) {
+			break
+		} else if err != nil {
+			return out, err
+		}
+		if !end.IsZero() && it.StartTime.After(end) {
+			break
+		}
+		out = append(out, it)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		if !p.Next() {
+			break
+		}
+	}
+	return out, nil
+}
+
+// CollectMetrics parses every item in [start, end] with mp and returns all metrics.
+// Zero start/end mean no bound. The caller owns the parser and is responsible for closing it.
+func CollectMetrics(p ItemParser, mp MetricParser, start, end time.Time) ([]Metric, error) {
+	var out []Metric
+	it := NewMetricIterator(p, mp, start, end)
+	for it.Next() {
+		out = append(out, it.Metrics()...)
+	}
+	return out, it.Err()
+}
+
+// ItemRate counts items in [start, end] per step bucket by head time, without parsing
+// metrics, and returns one "LogItems_Count" series. Zero start/end mean no bound.
+// The caller owns the parser and is responsible for closing it.
+func ItemRate(p ItemParser, start, end time.Time, step time.Duration) ([]Metric, error) {
+	var raw []Metric
+	if err := p.Seek(start); err != nil {
+		if errors.Is(err, ErrEOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	for {
+		it, err := p.Value()
+		if errors.Is(err, ErrNoItem) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if !end.IsZero() && it.StartTime.After(end) {
+			break
+		}
+		raw = append(raw, Metric{StartTime: it.StartTime, Name: "LogItems", Value: 1})
+		if !p.Next() {
+			break
+		}
+	}
+	agg := NewBucketAggregator(step, ModeCount)
+	agg.GroupBySource = false
+	return agg.Aggregate(raw), nil
+}
+
+// MetricIterator composes an item parser with a metric parser and yields the metrics of one
+// item per step, hiding the Seek/Next/Value sequence:
+//
+//	it := NewMetricIterator(p, mp, start, end)
+//	for it.Next() {
+//		use(it.Metrics())
+//	}
+//	if err := it.Err(); err != nil { ... }
+//
+// Reaching EOF or end is not an error. The caller owns the parser and closes it.
+type MetricIterator struct {
+	p          ItemParser
+	mp         MetricParser
+	start, end time.Time
+	started    bool
+	done       bool
+	item       LogItem
+	cur        []Metric
+	err        error
+}
+
+// NewMetricIterator iterates items in [start, end]; zero start/end mean no bound.
+func NewMetricIterator(p ItemParser, mp MetricParser, start, end time.Time) *MetricIterator {
+	return &MetricIterator{p: p, mp: mp, start: start, end: end}
+}
+
+// Next advances to the next item and parses its metrics. It returns false when iteration is over.
+func (it *MetricIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	if !it.started {
+		it.started = true
+		if err := it.p.Seek(it.start); err != nil {
+			if !errors.Is(err, ErrEOF) {
+				it.err = err
+			}
+			return it.stop()
+		}
+	} else if !it.p.Next() {
+		return it.stop()
+	}
+	item, err := it.p.Value()
+	if err != nil {
+		return it.stop()
+	}
+	if !it.end.IsZero() && item.StartTime.After(it.end) {
+		return it.stop()
+	}
+	it.item = item
+	it.cur = it.mp.Parse(item)
+	return true
+}
+
+func (it *MetricIterator) stop() bool {
+	it.done = true
+	it.item = LogItem{}
+	it.cur = nil
+	return false
+}
+
+// Metrics returns the metrics of the current item (may be empty for items without metrics).
+func (it *MetricIterator) Metrics() []Metric { return it.cur }
+
+// Item returns the current item.
+func (it *MetricIterator) Item() LogItem { return it.item }
+
+// Err returns the first non-EOF error encountered, if any.
+func (it *MetricIterator) Err() error { return it.err }
+
+// ItemSummary describes the item-type distribution and time span of a log stream.
+type ItemSummary struct {
+	Counts map[LogType]int
+	Total  int
+	First  time.Time // earliest item head time
+	Last   time.Time // latest item head time
+}
+
+// SummarizeItems iterates items in [start, end] and counts them by LogType.
+// Zero start/end mean no bound. The caller owns the parser and is responsible for closing it.
+func SummarizeItems(p ItemParser, start, end time.Time) (ItemSummary, error) {
+	sum := ItemSummary{Counts: make(map[LogType]int)}
+	if err := p.Seek(start); err != nil {
+		if errors.Is(err, ErrEOF) {
+			return sum, nil
+		}
+		return sum, err
+	}
+	for {
+		it, err := p.Value()
+		if err != nil {
+			break
+		}
+		if !end.IsZero() && it.StartTime.After(end) {
+			break
+		}
+		sum.Counts[it.Type]++
+		sum.Total++
+		if !it.StartTime.IsZero() {
+			if sum.First.IsZero() || it.StartTime.Before(sum.First) {
+				sum.First = it.StartTime
+			}
+			if it.StartTime.After(sum.Last) {
+				sum.Last = it.StartTime
+			}
+		}
+		if !p.Next() {
+			break
+		}
+	}
+	return sum, nil
+}
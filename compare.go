@@ -0,0 +1,68 @@
+package logparser
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+)
+
+// compareOrigin is the shared start OverlayRanges rebases both ranges onto.
+var compareOrigin = time.Unix(0, 0).UTC()
+
+// OverlayRanges rebases before and after, each taken from its own absolute time window, onto
+// one shared start so they can be drawn on a common "time since start" axis: every point is
+// shifted by its slice's earliest StartTime. Points are renamed "before"/"after" when a slice
+// holds a single metric name, else "<Name> before"/"<Name> after", and zero times are dropped.
+func OverlayRanges(before, after []Metric) []Metric {
+	out := make([]Metric, 0, len(before)+len(after))
+	out = appendRebased(out, before, "before")
+	return appendRebased(out, after, "after")
+}
+
+func appendRebased(out, ms []Metric, tag string) []Metric {
+	var first time.Time
+	names := make(map[string]struct{})
+	for _, m := range ms {
+		if m.StartTime.IsZero() {
+			continue
+		}
+		if first.IsZero() || m.StartTime.Before(first) {
+			first = m.StartTime
+		}
+		names[m.Name] = struct{}{}
+	}
+	for _, m := range ms {
+		if m.StartTime.IsZero() {
+			continue
+		}
+		m.StartTime = compareOrigin.Add(m.StartTime.Sub(first))
+		if len(names) == 1 {
+			m.Name = tag
+		} else {
+			m.Name += " " + tag
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// RenderComparison renders before and after overlaid (see OverlayRanges) with RelativeTime
+// ticks to outPath. d is not modified; its Start, End and EventMarkers, which refer to
+// absolute times, are ignored.
+func (d *Dialog) RenderComparison(before, after []Metric, outPath string) error {
+	var buf bytes.Buffer
+	if err := d.RenderComparisonTo(before, after, &buf); err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, buf.Bytes(), 0644)
+}
+
+// RenderComparisonTo is RenderComparison writing the SVG to out.
+func (d *Dialog) RenderComparisonTo(before, after []Metric, out io.Writer) error {
+	c := *d
+	c.RelativeTime = true
+	c.Start, c.End = time.Time{}, time.Time{}
+	c.EventMarkers = nil
+	return c.RenderTo(OverlayRanges(before, after), out)
+}
@@ -0,0 +1,65 @@
+package logparser
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ParseFilesConcurrent parses each file in its own goroutine, bounded to GOMAXPROCS workers,
+// and merges the metrics of items in [start, end] (zero means no bound). Every file gets a
+// fresh parser from makeParser and a fresh metric parser from makeMetricParser, so no state
+// is shared. The merged metrics are sorted by time (then name) for a deterministic order.
+// Per-file errors are joined; metrics from files that parsed successfully are still returned.
+func ParseFilesConcurrent(paths []string, start, end time.Time, makeParser func(path string) (ItemParser, error), makeMetricParser func() MetricParser) ([]Metric, error) {
+	results := make([][]Metric, len(paths))
+	errs := make([]error, len(paths))
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = parseFile(paths[i], start, end, makeParser, makeMetricParser)
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var out []Metric
+	for _, ms := range results {
+		out = append(out, ms...)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].StartTime.Equal(out[j].StartTime) {
+			return out[i].StartTime.Before(out[j].StartTime)
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, errors.Join(errs...)
+}
+
+func parseFile(path string, start, end time.Time, makeParser func(path string) (ItemParser, error), makeMetricParser func() MetricParser) ([]Metric, error) {
+	p, err := makeParser(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer p.Close()
+	ms, err := CollectMetrics(p, makeMetricParser(), start, end)
+	if err != nil {
+		return ms, fmt.Errorf("%s: %w", path, err)
+	}
+	return ms, nil
+}
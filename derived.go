@@ -0,0 +1,198 @@
+package logparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// metricCFs returns the sorted distinct CFs ("" for DB-wide) of metrics whose base name is base.
+func metricCFs(metrics []Metric, base string) []string {
+	set := make(map[string]struct{})
+	for _, m := range metrics {
+		if b, cf := MetricCF(m); b == base {
+			set[cf] = struct{}{}
+		}
+	}
+	out := make([]string, 0, len(set))
+	for cf := range set {
+		out = append(out, cf)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// withCF appends the "_<cf>" suffix used by the DUMP parser; an empty cf leaves name unchanged.
+func withCF(name, cf string) string {
+	if cf == "" {
+		return name
+	}
+	return name + "_" + cf
+}
+
+// CompactionBusyPct derives Compaction_Busy_Pct_<cf> = 100 * Compaction_Sec_<cf> / Uptime_Sec_<cf>
+// for every CF present in the raw (non-aggregated) DUMP metrics. Times with zero uptime yield 0.
+func CompactionBusyPct(metrics []Metric) []Metric {
+	var out []Metric
+	for _, cf := range metricCFs(metrics, "Compaction_Sec") {
+		formula := "100 * " + withCF("Compaction_Sec", cf) + " / " + withCF("Uptime_Sec", cf)
+		ms, err := ComputeExpression(metrics, formula, withCF("Compaction_Busy_Pct", cf))
+		if err != nil {
+			continue
+		}
+		for i := range ms {
+			ms[i].SourceType = LogTypeDump
+			if cf != "" {
+				ms[i].Labels = map[string]string{"cf": cf}
+			}
+		}
+		out = append(out, ms...)
+	}
+	return out
+}
+
+// ratioPerCF emits out_<cf> = (nums[0]_<cf> + nums[1]_<cf> + ...) / den_<cf> for every CF that
+// has nums[0] in the raw (non-aggregated) DUMP metrics. Times with a zero denominator yield 0.
+func ratioPerCF(metrics []Metric, nums []string, den, out string) []Metric {
+	var res []Metric
+	for _, cf := range metricCFs(metrics, nums[0]) {
+		terms := make([]string, len(nums))
+		for i, n := range nums {
+			terms[i] = withCF(n, cf)
+		}
+		formula := "(" + strings.Join(terms, " + ") + ") / " + withCF(den, cf)
+		ms, err := ComputeExpression(metrics, formula, withCF(out, cf))
+		if err != nil {
+			continue
+		}
+		for i := range ms {
+			ms[i].SourceType = LogTypeDump
+			if cf != "" {
+				ms[i].Labels = map[string]string{"cf": cf}
+			}
+		}
+		res = append(res, ms...)
+	}
+	return res
+}
+
+// ReadAmp derives Read_Amp_<cf> = Compaction_Read_GB_<cf> / Compaction_Write_GB_<cf> per CF.
+func ReadAmp(metrics []Metric) []Metric {
+	return ratioPerCF(metrics, []string{"Compaction_Read_GB"}, "Compaction_Write_GB", "Read_Amp")
+}
+
+// WriteAmp derives Write_Amp_<cf> = (Compaction_Write_GB_<cf> + Flush_GB_<cf>) / Flush_GB_<cf>
+// per CF: bytes written by flushes and compactions per byte flushed.
+func WriteAmp(metrics []Metric) []Metric {
+	return ratioPerCF(metrics, []string{"Compaction_Write_GB", "Flush_GB"}, "Flush_GB", "Write_Amp")
+}
+
+// StandardDerived names the built-in derived metric builders so configs and tools can request
+// them by key. Each builder takes raw (non-aggregated) metrics.
+var StandardDerived = map[string]func([]Metric) []Metric{
+	"compaction_busy_pct": CompactionBusyPct,
+	"read_amp":            ReadAmp,
+	"write_amp":           WriteAmp,
+	"restarts":            DetectRestarts,
+}
+
+// DeriveStandard runs the StandardDerived builders named by keys (case-insensitive) and
+// returns their combined output.
+func DeriveStandard(metrics []Metric, keys []string) ([]Metric, error) {
+	var out []Metric
+	for _, k := range keys {
+		fn, ok := StandardDerived[strings.ToLower(strings.TrimSpace(k))]
+		if !ok {
+			return nil, fmt.Errorf("unknown derived metric set %q", k)
+		}
+		out = append(out, fn(metrics)...)
+	}
+	return out, nil
+}
+
+// PercentOfTotal emits <name><suffix> = 100 * value / total for each metric whose Name is in
+// names, where total is the sum of those series at the same StartTime (e.g. each
+// LevelN_Size_MB as a share of all levels). Times whose total is zero are skipped.
+// Output is ordered by time, then by the order of names.
+func PercentOfTotal(metrics []Metric, names []string, suffix string) []Metric {
+	order := make(map[string]int, len(names))
+	for i, n := range names {
+		if _, ok := order[n]; !ok {
+			order[n] = i
+		}
+	}
+	byTime := make(map[time.Time][]Metric)
+	totals := make(map[time.Time]float64)
+	for _, m := range metrics {
+		if _, ok := order[m.Name]; !ok || m.StartTime.IsZero() {
+			continue
+		}
+		byTime[m.StartTime] = append(byTime[m.StartTime], m)
+		totals[m.StartTime] += m.Value
+	}
+	times := make([]time.Time, 0, len(byTime))
+	for t := range byTime {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	var out []Metric
+	for _, t := range times {
+		total := totals[t]
+		if total == 0 {
+			continue
+		}
+		ms := byTime[t]
+		sort.SliceStable(ms, func(i, j int) bool { return order[ms[i].Name] < order[ms[j].Name] })
+		for _, m := range ms {
+			out = append(out, Metric{
+				SourceType: m.SourceType,
+				StartTime:  t,
+				Name:       m.Name + suffix,
+				Value:      100 * m.Value / total,
+				Labels:     m.Labels,
+			})
+		}
+	}
+	return out
+}
+
+// DefaultRestartCounter is the cumulative counter DetectRestarts watches by default.
+const DefaultRestartCounter = "BC_Hit_Cum"
+
+// DetectRestarts emits Restart_Count = 1 at each time the cumulative counter
+// DefaultRestartCounter decreases, which happens when RocksDB restarts and its statistics reset.
+func DetectRestarts(metrics []Metric) []Metric {
+	return DetectRestartsOn(metrics, DefaultRestartCounter)
+}
+
+// DetectRestartsOn is DetectRestarts watching the cumulative counter named counter. Each
+// series of counter (by SourceType and labels) is checked in time order; restarts seen by
+// several series at the same time are reported once.
+func DetectRestartsOn(metrics []Metric, counter string) []Metric {
+	series := make(map[string][]Metric)
+	for _, m := range metrics {
+		if m.Name == counter && !m.StartTime.IsZero() {
+			k := string(m.SourceType) + "|" + labelsKey(m.Labels)
+			series[k] = append(series[k], m)
+		}
+	}
+	seen := make(map[time.Time]struct{})
+	var out []Metric
+	for _, pts := range series {
+		SortByTime(pts)
+		for i := 1; i < len(pts); i++ {
+			if pts[i].Value >= pts[i-1].Value {
+				continue
+			}
+			at := pts[i].StartTime
+			if _, ok := seen[at]; ok {
+				continue
+			}
+			seen[at] = struct{}{}
+			out = append(out, Metric{SourceType: pts[i].SourceType, StartTime: at, Name: "Restart_Count", Value: 1})
+		}
+	}
+	SortByTime(out)
+	return out
+}
@@ -0,0 +1,28 @@
+package logparser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWriteAmpCountsFlushBytes(t *testing.T) {
+	t0 := time.Date(2025, 11, 30, 3, 0, 0, 0, time.UTC)
+	t1 := t0.Add(10 * time.Minute)
+	ms := []Metric{
+		{Name: "Compaction_Write_GB_default", StartTime: t0, Value: 3, SourceType: LogTypeDump},
+		{Name: "Flush_GB_default", StartTime: t0, Value: 1, SourceType: LogTypeDump},
+		{Name: "Compaction_Write_GB_default", StartTime: t1, Value: 2, SourceType: LogTypeDump},
+		{Name: "Flush_GB_default", StartTime: t1, Value: 0, SourceType: LogTypeDump},
+	}
+	got := WriteAmp(ms)
+	if len(got) != 2 {
+		t.Fatalf("got %+v, want two points", got)
+	}
+	SortByTime(got)
+	// (3 + 1) / 1; a zero flush volume yields 0
+	for i, want := range []float64{4, 0} {
+		if got[i].Name != "Write_Amp_default" || got[i].Value != want || got[i].Labels["cf"] != "default" {
+			t.Errorf("point %d = %+v, want Write_Amp_default=%v cf=default", i, got[i], want)
+		}
+	}
+}
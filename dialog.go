@@ -1,7 +1,10 @@
 package logparser
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"sort"
 	"strings"
@@ -20,6 +23,89 @@ type Dialog struct {
 	Grid       bool
 	Title      string
 	TimeFormat string // for tick labels
+	// RelativeTime labels X ticks with the time since the earliest point ("+0", "+15m",
+	// "+1h30m") instead of TimeFormat, for series rebased onto a shared start (see
+	// RenderComparison).
+	RelativeTime bool
+	// MaxPoints caps the polyline vertices per series; longer series are downsampled with
+	// Largest-Triangle-Three-Buckets. Annotations still use the full data. 0 disables.
+	MaxPoints int
+	// YStrategy selects the Y-axis range: "p95" (default) caps at the 95th percentile with
+	// headroom to keep outliers from flattening the plot, "max" fits the true maximum so spikes
+	// and their annotations stay inside the plot, and "fixed" uses YMin/YMax as given.
+	YStrategy string
+	YMin      float64
+	YMax      float64
+	// SeriesBy adds dimensions to the series key besides Name: "source" for SourceType, "cf"
+	// for the column family (label or name suffix) and any other label key. Metrics that differ
+	// in these dimensions are drawn as distinct lines with distinct legend entries. Empty groups
+	// by Name only.
+	SeriesBy []string
+	// DropZeroSeries skips series whose every value is exactly 0.
+	DropZeroSeries bool
+	// Start and End clamp the drawn points to [Start, End]; zero means no bound.
+	Start, End time.Time
+	// EventMarkers are drawn as thin vertical lines (with an optional rotated label) at their
+	// times; markers outside the plotted time range are skipped.
+	EventMarkers []ChartMarker
+	// Thresholds are drawn as dashed horizontal lines across the plot (e.g. a P99 SLO) with
+	// their label in the right margin. Unless YStrategy is "fixed", the Y range is widened to
+	// keep every threshold visible.
+	Thresholds []ChartThreshold
+	// Stacked draws each series on top of the ones before it (in legend order) as a filled
+	// band, so the top line is the total. Peak value labels are omitted in this mode.
+	Stacked bool
+	// YLabel is drawn rotated along the Y axis; empty draws nothing.
+	YLabel string
+	// MissingSeries are listed greyed out with "(no data)" after the plotted series in the
+	// legend, so a configured name that matched nothing is visible on the chart itself.
+	MissingSeries []string
+}
+
+// ChartThreshold is a fixed Y value drawn across a chart; an empty Color draws it red.
+type ChartThreshold struct {
+	Value float64
+	Label string
+	Color string
+}
+
+// unitSuffixes maps the unit tokens used in metric names to their axis labels.
+var unitSuffixes = map[string]string{
+	"MB":   "MB",
+	"MBps": "MB/s",
+	"GB":   "GB",
+	"us":   "µs",
+	"Sec":  "s",
+	"Pct":  "%",
+}
+
+// DetectYUnit returns the axis label of the unit shared by every series name in metrics, taken
+// from the last unit token of each name (so CF and aggregation suffixes such as
+// "Flush_GB_default_Sum" still resolve to GB). It returns "" when a name has no recognizable
+// unit or the units are mixed.
+func DetectYUnit(metrics []Metric) string {
+	unit := ""
+	for _, m := range metrics {
+		u := ""
+		parts := strings.Split(m.Name, "_")
+		for i := len(parts) - 1; i > 0; i-- {
+			if l, ok := unitSuffixes[parts[i]]; ok {
+				u = l
+				break
+			}
+		}
+		if u == "" || (unit != "" && u != unit) {
+			return ""
+		}
+		unit = u
+	}
+	return unit
+}
+
+// ChartMarker marks a point in time on a chart, e.g. a compaction or a write stall.
+type ChartMarker struct {
+	At    time.Time
+	Label string
 }
 
 func NewDialog() *Dialog {
@@ -34,25 +120,84 @@ func NewDialog() *Dialog {
 	}
 }
 
+// NewDialogWithSize returns a Dialog like NewDialog with the given geometry. The plot area
+// must be non-empty: w > 2*pad and h > 2*pad, with pad >= 0.
+func NewDialogWithSize(w, h, pad int) (*Dialog, error) {
+	if pad < 0 {
+		return nil, fmt.Errorf("padding %d is negative", pad)
+	}
+	if w <= 2*pad || h <= 2*pad {
+		return nil, fmt.Errorf("size %dx%d leaves no plot area with padding %d", w, h, pad)
+	}
+	d := NewDialog()
+	d.Width, d.Height, d.Padding = w, h, pad
+	return d, nil
+}
+
+// geometry returns Width, Height and Padding clamped to a drawable layout: a non-positive
+// size falls back to the NewDialog default and the padding is shrunk to leave a plot area.
+func (d *Dialog) geometry() (w, h, pad int) {
+	w, h, pad = d.Width, d.Height, d.Padding
+	if w <= 0 {
+		w = 1200
+	}
+	if h <= 0 {
+		h = 600
+	}
+	if pad < 0 {
+		pad = 0
+	}
+	if m := (w - 1) / 2; pad > m {
+		pad = m
+	}
+	if m := (h - 1) / 2; pad > m {
+		pad = m
+	}
+	return w, h, pad
+}
+
 // Render writes an SVG chart to outPath.
 // Series are grouped by metric Name; each Name is drawn as one colored line.
+// The file is only written when rendering succeeds.
 func (d *Dialog) Render(metrics []Metric, outPath string) error {
+	var buf bytes.Buffer
+	if err := d.RenderTo(metrics, &buf); err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, buf.Bytes(), 0644)
+}
+
+// RenderTo writes the SVG chart to w (e.g. an HTTP response or an in-memory buffer).
+func (d *Dialog) RenderTo(metrics []Metric, out io.Writer) error {
+	metrics = FilterByTime(metrics, d.Start, d.End)
 	if len(metrics) == 0 {
 		return fmt.Errorf("no metrics to render")
 	}
 
-	// Group by Name
-	nameToPoints := map[string][]Metric{}
-	for _, m := range metrics {
-		if m.StartTime.IsZero() {
-			continue
+	// Group by Name (plus SeriesBy dimensions)
+	nameToPoints := GroupBySeriesKey(metrics, d.SeriesBy)
+	if d.DropZeroSeries {
+		for key, pts := range nameToPoints {
+			allZero := true
+			for _, p := range pts {
+				if p.Value != 0 {
+					allZero = false
+					break
+				}
+			}
+			if allZero {
+				delete(nameToPoints, key)
+			}
 		}
-		nameToPoints[m.Name] = append(nameToPoints[m.Name], m)
 	}
 	if len(nameToPoints) == 0 {
 		return fmt.Errorf("no valid metrics (missing StartTime)")
 	}
 
+	if d.Stacked {
+		stackSeries(nameToPoints)
+	}
+
 	// Sort each series by time; also collect global min/max
 	var minT, maxT time.Time
 	minSet := false
@@ -60,7 +205,7 @@ func (d *Dialog) Render(metrics []Metric, outPath string) error {
 	maxY := 0.0
 	allVals := make([]float64, 0, len(metrics))
 	for name, pts := range nameToPoints {
-		sort.Slice(pts, func(i, j int) bool { return pts[i].StartTime.Before(pts[j].StartTime) })
+		SortByTime(pts)
 		nameToPoints[name] = pts
 		for _, p := range pts {
 			if !minSet {
@@ -88,39 +233,36 @@ func (d *Dialog) Render(metrics []Metric, outPath string) error {
 		return fmt.Errorf("no points after filtering")
 	}
 	if !maxT.After(minT) {
-		// expand a tiny window to avoid divide-by-zero
-		maxT = minT.Add(time.Minute)
+		// expand a tiny window to avoid divide-by-zero, centered so a lone sample is mid-plot
+		minT = minT.Add(-30 * time.Second)
+		maxT = maxT.Add(30 * time.Second)
 	}
 
-	// Use a robust Y-maximum: 95th percentile of all values, with headroom and nice rounding.
-	if len(allVals) >= 3 {
-		sort.Float64s(allVals)
-		p95Idx := int(float64(len(allVals)-1) * 0.95)
-		if p95Idx < 0 {
-			p95Idx = 0
-		}
-		p95 := allVals[p95Idx]
-		if p95 > minY {
-			maxY = p95
-		}
-		// add 5% headroom
-		head := (maxY - minY) * 0.05
-		if head <= 0 {
-			head = 1
+	minY, maxY = d.yRange(allVals, minY, maxY)
+	if strings.ToLower(strings.TrimSpace(d.YStrategy)) != "fixed" {
+		for _, th := range d.Thresholds {
+			if th.Value > maxY {
+				maxY = niceUpper(th.Value + (th.Value-minY)*0.05)
+			}
+			if th.Value < minY {
+				minY = th.Value
+			}
 		}
-		maxY = maxY + head
-		// nice rounding up
-		maxY = niceUpper(maxY)
 	}
 	if maxY <= minY {
-		// expand a tiny vertical range
-		maxY = minY + 1
+		// expand a flat (e.g. single-sample) range from zero so the value sits mid-plot
+		switch {
+		case minY > 0:
+			minY, maxY = 0, niceUpper(2*minY)
+		case minY < 0:
+			minY, maxY = -niceUpper(-2*minY), 0
+		default:
+			maxY = minY + 1
+		}
 	}
 
 	// Layout
-	w := d.Width
-	h := d.Height
-	pad := d.Padding
+	w, h, pad := d.geometry()
 	plotW := float64(w - 2*pad)
 	plotH := float64(h - 2*pad)
 
@@ -146,6 +288,11 @@ func (d *Dialog) Render(metrics []Metric, outPath string) error {
 			w/2, pad/2, escapeXML(d.Title))
 	}
 
+	if strings.TrimSpace(d.YLabel) != "" {
+		fmt.Fprintf(&b, "<text x='%d' y='%d' transform='rotate(-90 %d %d)' text-anchor='middle' font-family='sans-serif' font-size='12' fill='#555'>%s</text>\n",
+			pad/4+6, h/2, pad/4+6, h/2, escapeXML(d.YLabel))
+	}
+
 	// Ticks/grid
 	if d.Grid {
 		gridColor := "#eee"
@@ -159,6 +306,9 @@ func (d *Dialog) Render(metrics []Metric, outPath string) error {
 			tickSec := int64(ratio * tRange)
 			tt := minT.Add(time.Duration(tickSec) * time.Second)
 			label := tt.Format(d.TimeFormat)
+			if d.RelativeTime {
+				label = relativeLabel(time.Duration(tickSec) * time.Second)
+			}
 			fmt.Fprintf(&b, "<text x='%.1f' y='%d' text-anchor='middle' font-family='sans-serif' font-size='11' fill='#555'>%s</text>\n", x, h-(pad/2), escapeXML(label))
 		}
 		// Y ticks: 6
@@ -176,6 +326,37 @@ func (d *Dialog) Render(metrics []Metric, outPath string) error {
 	fmt.Fprintf(&b, "<line x1='%d' y1='%d' x2='%d' y2='%d' stroke='%s' stroke-width='1'/>\n", pad, h-pad, w-pad, h-pad, axisColor) // X
 	fmt.Fprintf(&b, "<line x1='%d' y1='%d' x2='%d' y2='%d' stroke='%s' stroke-width='1'/>\n", pad, pad, pad, h-pad, axisColor)     // Y
 
+	// Event markers (under the series, clipped to the plot's time range)
+	for _, mk := range d.EventMarkers {
+		if mk.At.Before(minT) || mk.At.After(maxT) {
+			continue
+		}
+		x := timeToX(mk.At)
+		fmt.Fprintf(&b, "<line x1='%.2f' y1='%d' x2='%.2f' y2='%d' stroke='#999' stroke-width='1' stroke-dasharray='3,3'/>\n", x, pad, x, h-pad)
+		if mk.Label != "" {
+			fmt.Fprintf(&b, "<text x='%.2f' y='%d' transform='rotate(-90 %.2f %d)' text-anchor='end' font-family='sans-serif' font-size='10' fill='#777'>%s</text>\n",
+				x-3, pad+4, x-3, pad+4, escapeXML(mk.Label))
+		}
+	}
+
+	// Thresholds (under the series, labeled in the right margin)
+	for _, th := range d.Thresholds {
+		if th.Value < minY || th.Value > maxY {
+			continue
+		}
+		color := th.Color
+		if color == "" {
+			color = "#d62728"
+		}
+		y := valToY(th.Value)
+		fmt.Fprintf(&b, "<line x1='%d' y1='%.2f' x2='%d' y2='%.2f' stroke='%s' stroke-width='1' stroke-dasharray='6,4'/>\n", pad, y, w-pad, y, escapeXML(color))
+		label := th.Label
+		if label == "" {
+			label = fmt.Sprintf("%.4g", th.Value)
+		}
+		fmt.Fprintf(&b, "<text x='%d' y='%.2f' text-anchor='start' font-family='sans-serif' font-size='10' fill='%s'>%s</text>\n", w-pad+4, y+3, escapeXML(color), escapeXML(label))
+	}
+
 	// Series colors
 	colors := []string{
 		"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728",
@@ -190,15 +371,42 @@ func (d *Dialog) Render(metrics []Metric, outPath string) error {
 	}
 	sort.Strings(seriesNames)
 
+	// Stacked bands: fill between each series and the one below it (the axis for the first)
+	if d.Stacked {
+		var below []Metric
+		for i, name := range seriesNames {
+			pts := nameToPoints[name]
+			if len(pts) == 0 {
+				continue
+			}
+			var psb strings.Builder
+			for _, p := range pts {
+				fmt.Fprintf(&psb, "%.2f,%.2f ", timeToX(p.StartTime), valToY(p.Value))
+			}
+			if len(below) == 0 {
+				fmt.Fprintf(&psb, "%.2f,%d %.2f,%d", timeToX(pts[len(pts)-1].StartTime), h-pad, timeToX(pts[0].StartTime), h-pad)
+			}
+			for j := len(below) - 1; j >= 0; j-- {
+				fmt.Fprintf(&psb, "%.2f,%.2f ", timeToX(below[j].StartTime), valToY(below[j].Value))
+			}
+			fmt.Fprintf(&b, "<polygon fill='%s' fill-opacity='0.35' stroke='none' points='%s'/>\n", colors[i%len(colors)], strings.TrimSpace(psb.String()))
+			below = pts
+		}
+	}
+
 	for i, name := range seriesNames {
 		pts := nameToPoints[name]
 		if len(pts) == 0 {
 			continue
 		}
 		color := colors[i%len(colors)]
-		// Build polyline points
+		// Build polyline points (downsampled when the series is too dense)
+		drawPts := pts
+		if d.MaxPoints > 0 && len(pts) > d.MaxPoints {
+			drawPts = downsampleLTTB(pts, d.MaxPoints)
+		}
 		var psb strings.Builder
-		for _, p := range pts {
+		for _, p := range drawPts {
 			x := timeToX(p.StartTime)
 			y := valToY(p.Value)
 			fmt.Fprintf(&psb, "%.2f,%.2f ", x, y)
@@ -216,7 +424,11 @@ func (d *Dialog) Render(metrics []Metric, outPath string) error {
 				candidates = append(candidates, idxVal{idx: idx, val: p.Value})
 			}
 		}
-		if len(candidates) > 0 {
+		// a single sample draws no visible line: always mark and label it
+		if len(pts) == 1 && len(candidates) == 0 {
+			candidates = append(candidates, idxVal{idx: 0, val: pts[0].Value})
+		}
+		if len(candidates) > 0 && !d.Stacked {
 			sort.Slice(candidates, func(i, j int) bool { return candidates[i].val > candidates[j].val })
 			n := 3
 			if len(candidates) < n {
@@ -242,21 +454,150 @@ func (d *Dialog) Render(metrics []Metric, outPath string) error {
 	legendY := pad
 	lineH := 18
 	fmt.Fprintf(&b, "<rect x='%d' y='%d' width='200' height='%d' fill='#ffffff' stroke='#ddd'/>\n",
-		legendX, legendY-14, 14+len(seriesNames)*lineH)
+		legendX, legendY-14, 14+(len(seriesNames)+len(d.MissingSeries))*lineH)
 	for i, name := range seriesNames {
 		color := colors[i%len(colors)]
 		y := legendY + i*lineH
 		fmt.Fprintf(&b, "<line x1='%d' y1='%d' x2='%d' y2='%d' stroke='%s' stroke-width='3'/>\n", legendX+10, y, legendX+40, y, color)
 		fmt.Fprintf(&b, "<text x='%d' y='%d' font-family='sans-serif' font-size='12' fill='#333'>%s</text>\n", legendX+48, y+4, escapeXML(name))
 	}
+	for i, name := range d.MissingSeries {
+		y := legendY + (len(seriesNames)+i)*lineH
+		fmt.Fprintf(&b, "<line x1='%d' y1='%d' x2='%d' y2='%d' stroke='#bbb' stroke-width='3' stroke-dasharray='4,3'/>\n", legendX+10, y, legendX+40, y)
+		fmt.Fprintf(&b, "<text x='%d' y='%d' font-family='sans-serif' font-size='12' fill='#999'>%s (no data)</text>\n", legendX+48, y+4, escapeXML(name))
+	}
 
 	fmt.Fprintln(&b, "</svg>")
 
-	// Write file
-	if err := os.WriteFile(outPath, []byte(b.String()), 0644); err != nil {
-		return err
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// relativeLabel formats an X offset for RelativeTime ticks, dropping zero trailing units
+// ("1h30m0s" -> "+1h30m").
+func relativeLabel(d time.Duration) string {
+	if d <= 0 {
+		return "+0"
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return "+" + s
+}
+
+// yRange returns the Y-axis bounds for the data range [minY, maxY] per YStrategy.
+func (d *Dialog) yRange(allVals []float64, minY, maxY float64) (float64, float64) {
+	switch strings.ToLower(strings.TrimSpace(d.YStrategy)) {
+	case "fixed":
+		if d.YMax > d.YMin {
+			return d.YMin, d.YMax
+		}
+		return minY, maxY
+	case "max":
+		// true maximum plus headroom for the annotation labels above the peaks
+		head := (maxY - minY) * 0.05
+		if head <= 0 {
+			head = 1
+		}
+		return minY, niceUpper(maxY + head)
+	}
+	// Use a robust Y-maximum: 95th percentile of all values, with headroom and nice rounding.
+	if len(allVals) >= 3 {
+		sort.Float64s(allVals)
+		p95Idx := int(float64(len(allVals)-1) * 0.95)
+		if p95Idx < 0 {
+			p95Idx = 0
+		}
+		p95 := allVals[p95Idx]
+		if p95 > minY {
+			maxY = p95
+		}
+		// add 5% headroom
+		head := (maxY - minY) * 0.05
+		if head <= 0 {
+			head = 1
+		}
+		maxY = maxY + head
+		// nice rounding up
+		maxY = niceUpper(maxY)
+	}
+	return minY, maxY
+}
+
+// stackSeries sorts each series by time and replaces its values with the running total, in
+// series name order, of all series at the same time.
+func stackSeries(series map[string][]Metric) {
+	names := make([]string, 0, len(series))
+	for k := range series {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	totals := make(map[time.Time]float64)
+	for _, n := range names {
+		pts := append([]Metric(nil), series[n]...)
+		SortByTime(pts)
+		for i := range pts {
+			totals[pts[i].StartTime] += pts[i].Value
+			pts[i].Value = totals[pts[i].StartTime]
+		}
+		series[n] = pts
+	}
+}
+
+// downsampleLTTB reduces time-sorted pts to threshold points using Largest-Triangle-Three-Buckets,
+// which keeps the visual shape (peaks and troughs) of the series. First and last points are kept.
+func downsampleLTTB(pts []Metric, threshold int) []Metric {
+	n := len(pts)
+	if threshold >= n || threshold <= 2 {
+		if threshold <= 2 && n > 2 {
+			return []Metric{pts[0], pts[n-1]}
+		}
+		return pts
+	}
+	base := pts[0].StartTime
+	x := func(i int) float64 { return pts[i].StartTime.Sub(base).Seconds() }
+	out := make([]Metric, 0, threshold)
+	out = append(out, pts[0])
+	every := float64(n-2) / float64(threshold-2)
+	a := 0
+	for i := 0; i < threshold-2; i++ {
+		// average point of the next bucket
+		avgStart := int(float64(i+1)*every) + 1
+		avgEnd := int(float64(i+2)*every) + 1
+		if avgEnd > n {
+			avgEnd = n
+		}
+		avgX, avgY := 0.0, 0.0
+		for j := avgStart; j < avgEnd; j++ {
+			avgX += x(j)
+			avgY += pts[j].Value
+		}
+		if cnt := float64(avgEnd - avgStart); cnt > 0 {
+			avgX /= cnt
+			avgY /= cnt
+		}
+		// pick the point in the current bucket forming the largest triangle with a and the average
+		rangeStart := int(float64(i)*every) + 1
+		rangeEnd := int(float64(i+1)*every) + 1
+		ax, ay := x(a), pts[a].Value
+		maxArea := -1.0
+		next := rangeStart
+		for j := rangeStart; j < rangeEnd; j++ {
+			area := math.Abs((ax-avgX)*(pts[j].Value-ay) - (ax-x(j))*(avgY-ay))
+			if area > maxArea {
+				maxArea = area
+				next = j
+			}
+		}
+		out = append(out, pts[next])
+		a = next
 	}
-	return nil
+	out = append(out, pts[n-1])
+	return out
 }
 
 // niceUpper rounds up v to a "nice" number (1, 2, 5) * 10^k
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -12,9 +13,12 @@ import (
 // - Only metrics with the same StartTime are combined.
 // - If multiple metrics share the same (StartTime, Name), they are summed first, then used in the expression.
 // - Supports +, -, *, / and parentheses, constants, and variable names (metric names).
+// - Functions: round(x), floor(x), ceil(x), clamp(x, lo, hi).
 // - Variable token format: [A-Za-z_][A-Za-z0-9_]* (must match Metric.Name exactly).
-// - Constants: decimal numbers like 123, 45.6
+// - Constants: decimal numbers like 123, 45.6, 1e6, 2.5E-3 or 1_000_000
 // - Division by zero yields 0 (instead of +Inf).
+// - Results carry the SourceType shared by every variable's metrics at that time, or "EXPR"
+//   when sources are mixed (or the formula has no variables).
 type MetricExpressionCalculator struct{}
 
 // Compute evaluates the given formula across the provided metrics.
@@ -34,6 +38,8 @@ func (MetricExpressionCalculator) Compute(metrics []Metric, formula string, outN
 	}
 	// Aggregate values by (time -> name -> sum)
 	timeToNameSum := make(map[time.Time]map[string]float64)
+	// source per (time, name); "" once a name has metrics from several sources
+	timeToNameSrc := make(map[time.Time]map[string]LogType)
 	seenTimes := make(map[time.Time]struct{})
 	for _, m := range metrics {
 		if m.StartTime.IsZero() {
@@ -47,6 +53,16 @@ func (MetricExpressionCalculator) Compute(metrics []Metric, formula string, outN
 			timeToNameSum[tt] = ns
 		}
 		ns[name] += m.Value
+		srcs, ok := timeToNameSrc[tt]
+		if !ok {
+			srcs = make(map[string]LogType)
+			timeToNameSrc[tt] = srcs
+		}
+		if src, seen := srcs[name]; !seen {
+			srcs[name] = m.SourceType
+		} else if src != m.SourceType {
+			srcs[name] = ""
+		}
 		seenTimes[tt] = struct{}{}
 	}
 	// Determine time keys to evaluate on: intersection across variables if any, otherwise all times present.
@@ -91,7 +107,7 @@ func (MetricExpressionCalculator) Compute(metrics []Metric, formula string, outN
 			return nil, fmt.Errorf("evaluate at %s: %w", tt.Format("2006/01/02-15:04:05.000000"), err)
 		}
 		out = append(out, Metric{
-			SourceType: "EXPR",
+			SourceType: exprSource(vars, timeToNameSrc[tt]),
 			StartTime:  tt,
 			Name:       outName,
 			Value:      val,
@@ -100,6 +116,22 @@ func (MetricExpressionCalculator) Compute(metrics []Metric, formula string, outN
 	return out, nil
 }
 
+// exprSource returns the SourceType shared by all vars in srcs, or "EXPR" when they differ.
+func exprSource(vars map[string]struct{}, srcs map[string]LogType) LogType {
+	var st LogType
+	for v := range vars {
+		src := srcs[v]
+		if src == "" || (st != "" && src != st) {
+			return "EXPR"
+		}
+		st = src
+	}
+	if st == "" {
+		return "EXPR"
+	}
+	return st
+}
+
 // Public helper for ad-hoc use.
 func ComputeExpression(metrics []Metric, formula, outName string) ([]Metric, error) {
 	return (MetricExpressionCalculator{}).Compute(metrics, formula, outName)
@@ -116,8 +148,18 @@ const (
 	tokOp
 	tokLParen
 	tokRParen
+	tokFunc
+	tokComma
 )
 
+// exprFuncs maps the supported function names to their arity.
+var exprFuncs = map[string]int{
+	"round": 1,
+	"floor": 1,
+	"ceil":  1,
+	"clamp": 3,
+}
+
 type token struct {
 	kind   tokKind
 	num    float64
@@ -157,33 +199,36 @@ func tokenize(expr string) ([]token, error) {
 			toks = append(toks, token{kind: tokRParen, text: ")"})
 			i++
 			continue
+		case ',':
+			toks = append(toks, token{kind: tokComma, text: ","})
+			i++
+			continue
 		}
 		if isNameStart(ch) {
 			j := i + 1
 			for j < len(s) && isNameChar(s[j]) {
 				j++
 			}
+			// a known function name followed by '(' is a call, anything else a metric name
+			k := j
+			for k < len(s) && (s[k] == ' ' || s[k] == '\t') {
+				k++
+			}
+			if _, ok := exprFuncs[s[i:j]]; ok && k < len(s) && s[k] == '(' {
+				toks = append(toks, token{kind: tokFunc, text: s[i:j]})
+				i = j
+				continue
+			}
 			toks = append(toks, token{kind: tokName, text: s[i:j]})
 			i = j
 			continue
 		}
 		if isDigit(ch) || ch == '.' {
-			j := i + 1
-			dot := ch == '.'
-			for j < len(s) {
-				if isDigit(s[j]) {
-					j++
-				} else if s[j] == '.' && !dot {
-					dot = true
-					j++
-				} else {
-					break
-				}
+			j := scanNumber(s, i)
+			num, err := strconv.ParseFloat(strings.ReplaceAll(s[i:j], "_", ""), 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad number %q at %d", s[i:j], i)
 			}
-			var num float64
-			var n int
-			fmt.Sscanf(s[i:j], "%f", &num)
-			_ = n
 			toks = append(toks, token{kind: tokNumber, num: num, text: s[i:j]})
 			i = j
 			continue
@@ -193,12 +238,46 @@ func tokenize(expr string) ([]token, error) {
 	return toks, nil
 }
 
+// scanNumber returns the end of the numeric literal starting at i: digits with an optional
+// single '.', underscores between digits (1_000_000) and an optional exponent (1e6, 2.5E-3).
+func scanNumber(s string, i int) int {
+	j := i
+	dot := false
+	for j < len(s) {
+		if isDigit(s[j]) {
+			j++
+		} else if s[j] == '.' && !dot {
+			dot = true
+			j++
+		} else if s[j] == '_' && j > i && isDigit(s[j-1]) && j+1 < len(s) && isDigit(s[j+1]) {
+			j++
+		} else {
+			break
+		}
+	}
+	if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+		k := j + 1
+		if k < len(s) && (s[k] == '+' || s[k] == '-') {
+			k++
+		}
+		if k < len(s) && isDigit(s[k]) {
+			for k < len(s) && isDigit(s[k]) {
+				k++
+			}
+			j = k
+		}
+	}
+	return j
+}
+
 func precedence(op string) int {
 	switch op {
 	case "+", "-":
 		return 1
 	case "*", "/":
 		return 2
+	case "neg":
+		return 3
 	default:
 		return -1
 	}
@@ -212,7 +291,7 @@ func parseExpressionToRPN(expr string) ([]token, map[string]struct{}, error) {
 	output := make([]token, 0, len(toks))
 	opstack := make([]token, 0, len(toks))
 	vars := make(map[string]struct{})
-	for _, tk := range toks {
+	for i, tk := range toks {
 		switch tk.kind {
 		case tokNumber, tokName:
 			output = append(output, tk)
@@ -220,6 +299,16 @@ func parseExpressionToRPN(expr string) ([]token, map[string]struct{}, error) {
 				vars[tk.text] = struct{}{}
 			}
 		case tokOp:
+			// A sign with no left operand (at the start, or after '(', ',' or an operator) is
+			// unary: "-x" is evaluated as 0 - x, binding tighter than '*' and '/', and '+' is
+			// dropped. Nothing is popped for it since its operand has not been read yet.
+			if (tk.text == "-" || tk.text == "+") && (i == 0 || toks[i-1].kind == tokOp || toks[i-1].kind == tokLParen || toks[i-1].kind == tokComma) {
+				if tk.text == "-" {
+					output = append(output, token{kind: tokNumber, num: 0, text: "0"})
+					opstack = append(opstack, token{kind: tokOp, text: "neg"})
+				}
+				continue
+			}
 			for len(opstack) > 0 {
 				top := opstack[len(opstack)-1]
 				if top.kind == tokOp && precedence(top.text) >= precedence(tk.text) {
@@ -230,8 +319,17 @@ func parseExpressionToRPN(expr string) ([]token, map[string]struct{}, error) {
 				}
 			}
 			opstack = append(opstack, tk)
-		case tokLParen:
+		case tokLParen, tokFunc:
 			opstack = append(opstack, tk)
+		case tokComma:
+			// flush the current argument up to the call's '('
+			for len(opstack) > 0 && opstack[len(opstack)-1].kind != tokLParen {
+				output = append(output, opstack[len(opstack)-1])
+				opstack = opstack[:len(opstack)-1]
+			}
+			if len(opstack) == 0 {
+				return nil, nil, fmt.Errorf("misplaced ','")
+			}
 		case tokRParen:
 			found := false
 			for len(opstack) > 0 {
@@ -246,12 +344,16 @@ func parseExpressionToRPN(expr string) ([]token, map[string]struct{}, error) {
 			if !found {
 				return nil, nil, fmt.Errorf("mismatched parentheses")
 			}
+			if len(opstack) > 0 && opstack[len(opstack)-1].kind == tokFunc {
+				output = append(output, opstack[len(opstack)-1])
+				opstack = opstack[:len(opstack)-1]
+			}
 		default:
 			return nil, nil, fmt.Errorf("invalid token")
 		}
 	}
 	for i := len(opstack) - 1; i >= 0; i-- {
-		if opstack[i].kind == tokLParen || opstack[i].kind == tokRParen {
+		if opstack[i].kind == tokLParen || opstack[i].kind == tokRParen || opstack[i].kind == tokFunc {
 			return nil, nil, fmt.Errorf("mismatched parentheses")
 		}
 		output = append(output, opstack[i])
@@ -293,7 +395,7 @@ func evalRPN(rpn []token, env map[string]float64) (float64, error) {
 			switch tk.text {
 			case "+":
 				push(a + b)
-			case "-":
+			case "-", "neg":
 				push(a - b)
 			case "*":
 				push(a * b)
@@ -306,6 +408,27 @@ func evalRPN(rpn []token, env map[string]float64) (float64, error) {
 			default:
 				return 0, fmt.Errorf("unknown operator %q", tk.text)
 			}
+		case tokFunc:
+			args := make([]float64, exprFuncs[tk.text])
+			for i := len(args) - 1; i >= 0; i-- {
+				v, err := pop()
+				if err != nil {
+					return 0, fmt.Errorf("%s: wrong number of arguments", tk.text)
+				}
+				args[i] = v
+			}
+			switch tk.text {
+			case "round":
+				push(math.Round(args[0]))
+			case "floor":
+				push(math.Floor(args[0]))
+			case "ceil":
+				push(math.Ceil(args[0]))
+			case "clamp":
+				push(math.Max(args[1], math.Min(args[2], args[0])))
+			default:
+				return 0, fmt.Errorf("unknown function %q", tk.text)
+			}
 		default:
 			return 0, fmt.Errorf("bad token in evaluation")
 		}
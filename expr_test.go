@@ -0,0 +1,106 @@
+package logparser
+
+import (
+	"testing"
+	"time"
+)
+
+func evalFormula(t *testing.T, formula string, env map[string]float64) float64 {
+	t.Helper()
+	rpn, _, err := parseExpressionToRPN(formula)
+	if err != nil {
+		t.Fatalf("parse %q: %v", formula, err)
+	}
+	v, err := evalRPN(rpn, env)
+	if err != nil {
+		t.Fatalf("eval %q: %v", formula, err)
+	}
+	return v
+}
+
+func TestExprNegativeInputs(t *testing.T) {
+	env := map[string]float64{"x": -2.5, "y": 4}
+	cases := []struct {
+		formula string
+		want    float64
+	}{
+		{"round(-2.5)", -3},
+		{"floor(-2.5)", -3},
+		{"ceil(-2.5)", -2},
+		{"round(x)", -3},
+		{"floor(x)", -3},
+		{"ceil(x)", -2},
+		{"round(-x)", 3},
+		{"-2.5", -2.5},
+		{"-x", 2.5},
+		{"- -x", -2.5},
+		{"+y", 4},
+		{"2 * -3", -6},
+		{"-y * 2", -8},
+		{"1 - -1", 2},
+		{"-(1 + 2)", -3},
+		{"y / -2", -2},
+		{"-1e3", -1000},
+		{"clamp(x, -1, 1)", -1},
+		{"clamp(-0.5, -1, 1)", -0.5},
+		{"clamp(y, -1, 1)", 1},
+	}
+	for _, c := range cases {
+		if got := evalFormula(t, c.formula, env); got != c.want {
+			t.Errorf("%s = %v, want %v", c.formula, got, c.want)
+		}
+	}
+}
+
+func TestExprClampLoAboveHi(t *testing.T) {
+	// lo > hi is not rejected: the lower bound wins, whatever x is
+	for _, f := range []string{"clamp(0, 5, 1)", "clamp(3, 5, 1)", "clamp(9, 5, 1)", "clamp(-9, 5, 1)"} {
+		if got := evalFormula(t, f, nil); got != 5 {
+			t.Errorf("%s = %v, want 5", f, got)
+		}
+	}
+}
+
+func TestExprNonFiniteResultsAreZero(t *testing.T) {
+	env := map[string]float64{"a": 1, "z": 0, "big": 1e308}
+	for _, f := range []string{
+		"a / 0",
+		"a / z",
+		"z / z",
+		"-a / z",
+		"big * 10",
+		"-big * 10",
+		"big * 10 - big * 10",
+	} {
+		if got := evalFormula(t, f, env); got != 0 {
+			t.Errorf("%s = %v, want 0", f, got)
+		}
+	}
+}
+
+func TestExprBadCalls(t *testing.T) {
+	for _, f := range []string{"round()", "clamp(1, 2)", "round(1", "1 -"} {
+		rpn, _, err := parseExpressionToRPN(f)
+		if err == nil {
+			_, err = evalRPN(rpn, nil)
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", f)
+		}
+	}
+}
+
+func TestComputeExpressionNegativeSeries(t *testing.T) {
+	at := time.Date(2025, 11, 30, 3, 0, 0, 0, time.UTC)
+	ms := []Metric{
+		{Name: "A", StartTime: at, Value: -3.7, SourceType: LogTypeDump},
+		{Name: "B", StartTime: at, Value: 0, SourceType: LogTypeDump},
+	}
+	out, err := ComputeExpression(ms, "round(-A) + A / B", "R")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Name != "R" || out[0].Value != 4 {
+		t.Fatalf("got %+v, want one R=4", out)
+	}
+}
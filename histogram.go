@@ -0,0 +1,128 @@
+package logparser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// Bucket is one bin of a value histogram: Count samples fell in [Lo, Hi) (the last bin also
+// holds Hi itself).
+type Bucket struct {
+	Lo, Hi float64
+	Count  int
+}
+
+// Histogram bins the values of the metrics named name into bins equal-width buckets spanning
+// their minimum to maximum, e.g. the distribution of DB_Get_P99_us over a time range. When
+// every value is equal the single value range is widened to 1 so the bins are non-empty.
+func Histogram(metrics []Metric, name string, bins int) ([]Bucket, error) {
+	if bins <= 0 {
+		return nil, fmt.Errorf("bad bin count %d", bins)
+	}
+	var vals []float64
+	for _, m := range metrics {
+		if m.Name == name && !math.IsNaN(m.Value) && !math.IsInf(m.Value, 0) {
+			vals = append(vals, m.Value)
+		}
+	}
+	if len(vals) == 0 {
+		return nil, fmt.Errorf("no values for metric %q", name)
+	}
+	lo, hi := vals[0], vals[0]
+	for _, v := range vals[1:] {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+	width := (hi - lo) / float64(bins)
+	out := make([]Bucket, bins)
+	for i := range out {
+		out[i].Lo = lo + float64(i)*width
+		out[i].Hi = lo + float64(i+1)*width
+	}
+	out[bins-1].Hi = hi
+	for _, v := range vals {
+		i := int((v - lo) / width)
+		if i >= bins {
+			i = bins - 1
+		}
+		out[i].Count++
+	}
+	return out, nil
+}
+
+// RenderHistogram writes buckets as an SVG bar chart to outPath: value on X, sample count on Y.
+// It uses the Dialog's geometry, Background, Grid, Title and YLabel; the time-series options
+// do not apply.
+func (d *Dialog) RenderHistogram(buckets []Bucket, outPath string) error {
+	var buf bytes.Buffer
+	if err := d.RenderHistogramTo(buckets, &buf); err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, buf.Bytes(), 0644)
+}
+
+// RenderHistogramTo is RenderHistogram writing the SVG to out.
+func (d *Dialog) RenderHistogramTo(buckets []Bucket, out io.Writer) error {
+	if len(buckets) == 0 {
+		return fmt.Errorf("no buckets to render")
+	}
+	w, h, pad := d.geometry()
+	plotW := float64(w - 2*pad)
+	plotH := float64(h - 2*pad)
+	maxC := 0
+	for _, bk := range buckets {
+		if bk.Count > maxC {
+			maxC = bk.Count
+		}
+	}
+	maxY := niceUpper(float64(maxC))
+	barW := plotW / float64(len(buckets))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns='http://www.w3.org/2000/svg' width='%d' height='%d' viewBox='0 0 %d %d'>\n", w, h, w, h)
+	fmt.Fprintf(&b, "<rect x='0' y='0' width='%d' height='%d' fill='%s'/>\n", w, h, d.Background)
+	if strings.TrimSpace(d.Title) != "" {
+		fmt.Fprintf(&b, "<text x='%d' y='%d' text-anchor='middle' font-family='sans-serif' font-size='18' fill='#333'>%s</text>\n",
+			w/2, pad/2, escapeXML(d.Title))
+	}
+	if strings.TrimSpace(d.YLabel) != "" {
+		fmt.Fprintf(&b, "<text x='%d' y='%d' transform='rotate(-90 %d %d)' text-anchor='middle' font-family='sans-serif' font-size='12' fill='#555'>%s</text>\n",
+			pad/4+6, h/2, pad/4+6, h/2, escapeXML(d.YLabel))
+	}
+	// Y ticks/grid over counts
+	for i := 0; i <= 6; i++ {
+		ratio := float64(i) / 6.0
+		y := float64(h-pad) - ratio*plotH
+		if d.Grid {
+			fmt.Fprintf(&b, "<line x1='%d' y1='%.1f' x2='%d' y2='%.1f' stroke='#eee' stroke-width='1'/>\n", pad, y, w-pad, y)
+		}
+		fmt.Fprintf(&b, "<text x='%d' y='%.1f' text-anchor='end' font-family='sans-serif' font-size='11' fill='#555'>%.4g</text>\n", pad-8, y+4, ratio*maxY)
+	}
+	// Bars, labelled with their lower bound; at most ~12 X labels to keep them readable
+	labelEvery := (len(buckets) + 11) / 12
+	for i, bk := range buckets {
+		x := float64(pad) + float64(i)*barW
+		bh := float64(bk.Count) / maxY * plotH
+		fmt.Fprintf(&b, "<rect x='%.2f' y='%.2f' width='%.2f' height='%.2f' fill='#1f77b4' stroke='#ffffff' stroke-width='1'><title>[%.4g, %.4g): %d</title></rect>\n",
+			x, float64(h-pad)-bh, barW, bh, bk.Lo, bk.Hi, bk.Count)
+		if i%labelEvery == 0 {
+			fmt.Fprintf(&b, "<text x='%.1f' y='%d' text-anchor='middle' font-family='sans-serif' font-size='11' fill='#555'>%.4g</text>\n", x, h-(pad/2), bk.Lo)
+		}
+	}
+	last := buckets[len(buckets)-1]
+	fmt.Fprintf(&b, "<text x='%d' y='%d' text-anchor='middle' font-family='sans-serif' font-size='11' fill='#555'>%.4g</text>\n", w-pad, h-(pad/2), last.Hi)
+	// Axes
+	fmt.Fprintf(&b, "<line x1='%d' y1='%d' x2='%d' y2='%d' stroke='#222' stroke-width='1'/>\n", pad, h-pad, w-pad, h-pad)
+	fmt.Fprintf(&b, "<line x1='%d' y1='%d' x2='%d' y2='%d' stroke='#222' stroke-width='1'/>\n", pad, pad, pad, h-pad)
+	fmt.Fprintln(&b, "</svg>")
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
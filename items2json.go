@@ -0,0 +1,65 @@
+package logparser
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Items2JSON writes []LogItem as newline-delimited JSON, one object per item such as
+// {"time":"2025/11/30-03:00:00.000001","type":"DUMP","lines":["..."]}, so the classified
+// item stream can be consumed without re-implementing item grouping.
+type Items2JSON struct {
+	// TimeFormat formats the item head time (default DefaultTimeLayout, as in the LOG); zero
+	// times are written as "".
+	TimeFormat string
+}
+
+func NewItems2JSON() *Items2JSON {
+	return &Items2JSON{TimeFormat: DefaultTimeLayout}
+}
+
+type itemJSON struct {
+	Time  string   `json:"time"`
+	Type  LogType  `json:"type"`
+	Lines []string `json:"lines"`
+}
+
+// WriteFile writes items to the given path; "-" writes to stdout.
+func (w *Items2JSON) WriteFile(items []LogItem, path string) error {
+	if path == "-" {
+		return w.Write(items, os.Stdout)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("open items output: %w", err)
+	}
+	defer f.Close()
+	return w.Write(items, f)
+}
+
+// Write emits one JSON line per item.
+func (w *Items2JSON) Write(items []LogItem, out io.Writer) error {
+	layout := w.TimeFormat
+	if layout == "" {
+		layout = DefaultTimeLayout
+	}
+	bw := bufio.NewWriter(out)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	for _, it := range items {
+		rec := itemJSON{Type: it.Type, Lines: it.Lines}
+		if !it.StartTime.IsZero() {
+			rec.Time = it.StartTime.Format(layout)
+		}
+		if rec.Lines == nil {
+			rec.Lines = []string{}
+		}
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("write item: %w", err)
+		}
+	}
+	return bw.Flush()
+}
@@ -2,13 +2,18 @@ package logparser
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Metric2CSV persists []Metric to a CSV file.
-// Columns: Time, SourceType, Name, Value, CF
+// Columns: Time, SourceType, Name, Value, Labels (k=v pairs joined by ',', sorted by key)
 type Metric2CSV struct {
 	// IncludeHeader controls whether to write the CSV header row.
 	// When Append is true and the file already exists with non-zero size,
@@ -18,6 +23,22 @@ type Metric2CSV struct {
 	Comma rune
 	// Append controls whether to append to the output file (vs overwrite).
 	Append bool
+	// DropZeroSeries skips series (Name, SourceType, Labels) whose every value is exactly 0.
+	DropZeroSeries bool
+	// Start and End clamp the rows written to [Start, End]; zero means no bound.
+	Start, End time.Time
+	// Format ('g', 'f' or 'e') and Precision control how values are written, as in
+	// strconv.FormatFloat. Precision -1 is the shortest exact representation. A zero Format
+	// keeps the default ('g', -1).
+	Format    byte
+	Precision int
+	// TimeFormat is the layout of the Time column (default DefaultTimeLayout); use
+	// time.RFC3339Nano to match RFC3339 logs or -start/-end values.
+	TimeFormat string
+
+	// set between Open and Close
+	f  *os.File
+	cw *csv.Writer
 }
 
 func NewMetric2CSV() *Metric2CSV {
@@ -25,12 +46,31 @@ func NewMetric2CSV() *Metric2CSV {
 		IncludeHeader: true,
 		Comma:         ',',
 		Append:        false,
+		Format:        'g',
+		Precision:     -1,
+		TimeFormat:    DefaultTimeLayout,
 	}
 }
 
 // WriteFile writes metrics to the given path as CSV.
-// It ensures consistent column order: Time,SourceType,Name,Value,CF.
+// It ensures consistent column order: Time,SourceType,Name,Value,Labels.
 func (w *Metric2CSV) WriteFile(metrics []Metric, path string) error {
+	if err := w.Open(path); err != nil {
+		return err
+	}
+	if err := w.Write(metrics); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Open creates (or, with Append, extends) the CSV file at path and writes the header when
+// enabled, so batches can then be streamed with Write and finished with Close.
+func (w *Metric2CSV) Open(path string) error {
+	if w.f != nil {
+		return errors.New("csv output already open")
+	}
 	flag := os.O_CREATE | os.O_WRONLY
 	if w.Append {
 		flag |= os.O_APPEND
@@ -41,7 +81,6 @@ func (w *Metric2CSV) WriteFile(metrics []Metric, path string) error {
 	if err != nil {
 		return fmt.Errorf("open csv output: %w", err)
 	}
-	defer f.Close()
 
 	writeHeader := w.IncludeHeader
 	if w.Append {
@@ -54,37 +93,113 @@ func (w *Metric2CSV) WriteFile(metrics []Metric, path string) error {
 	if w.Comma != 0 {
 		cw.Comma = w.Comma
 	}
-
 	if writeHeader {
-		if err := cw.Write([]string{"Time", "SourceType", "Name", "Value"}); err != nil {
+		if err := cw.Write([]string{"Time", "SourceType", "Name", "Value", "Labels"}); err != nil {
+			_ = f.Close()
 			return fmt.Errorf("write header: %w", err)
 		}
 	}
+	w.f, w.cw = f, cw
+	return nil
+}
+
+// Write appends one batch of metrics to the file opened by Open and flushes it.
+// DropZeroSeries only sees the current batch.
+func (w *Metric2CSV) Write(metrics []Metric) error {
+	if w.cw == nil {
+		return errors.New("csv output not open")
+	}
+	metrics = FilterByTime(metrics, w.Start, w.End)
+	if w.DropZeroSeries {
+		metrics = DropZeroSeries(metrics)
+	}
+	layout := w.TimeFormat
+	if layout == "" {
+		layout = DefaultTimeLayout
+	}
 	for _, m := range metrics {
-		ts := m.StartTime
-		if ts.IsZero() {
-			// Keep empty if zero
-		}
 		timeStr := ""
-		if !ts.IsZero() {
-			// format with micros
-			timeStr = ts.Format("2006/01/02-15:04:05.000000")
+		if !m.StartTime.IsZero() {
+			timeStr = m.StartTime.Format(layout)
 		}
 		row := []string{
 			timeStr,
 			string(m.SourceType),
 			m.Name,
-			strconv.FormatFloat(m.Value, 'g', -1, 64),
+			w.formatValue(m.Value),
+			labelsKey(m.Labels),
 		}
-		if err := cw.Write(row); err != nil {
+		if err := w.cw.Write(row); err != nil {
 			return fmt.Errorf("write row: %w", err)
 		}
 	}
+	w.cw.Flush()
+	if err := w.cw.Error(); err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the file opened by Open. Closing an unopened writer is a no-op.
+func (w *Metric2CSV) Close() error {
+	if w.f == nil {
+		return nil
+	}
+	f, cw := w.f, w.cw
+	w.f, w.cw = nil, nil
 	cw.Flush()
 	if err := cw.Error(); err != nil {
+		_ = f.Close()
 		return fmt.Errorf("flush: %w", err)
 	}
-	return nil
+	return f.Close()
 }
 
+func (w *Metric2CSV) formatValue(v float64) string {
+	if w.Format == 0 {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(v, w.Format, w.Precision, 64)
+}
 
+// WriteSplit writes one CSV per metric Name to <dir>/<name>.csv with the same columns as
+// WriteFile. Names are sanitized for the filesystem; names that sanitize to the same file
+// share it. dir is created if missing.
+func (w *Metric2CSV) WriteSplit(metrics []Metric, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create csv dir: %w", err)
+	}
+	byFile := make(map[string][]Metric)
+	for _, m := range metrics {
+		f := sanitizeFileName(m.Name) + ".csv"
+		byFile[f] = append(byFile[f], m)
+	}
+	files := make([]string, 0, len(byFile))
+	for f := range byFile {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		if err := w.WriteFile(byFile[f], filepath.Join(dir, f)); err != nil {
+			return fmt.Errorf("%s: %w", f, err)
+		}
+	}
+	return nil
+}
+
+// sanitizeFileName replaces characters outside [A-Za-z0-9._-] with '_' so a metric name is a
+// safe file name; an empty or dot-only result becomes "_".
+func sanitizeFileName(name string) string {
+	s := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	if strings.Trim(s, ".") == "" {
+		return "_"
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package logparser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metric2Influx writes []Metric as InfluxDB line protocol:
+//
+//	rocksdb,cf=default,source=DUMP Flush_GB=0.1 1764471600000000000
+//
+// The CF suffix is moved from the metric name into the cf tag (see MetricCF); other Labels become tags.
+type Metric2Influx struct {
+	// Measurement is the measurement name used when NameAsMeasurement is false (default "rocksdb").
+	Measurement string
+	// NameAsMeasurement uses the metric name as the measurement with a single "value" field,
+	// instead of a fixed measurement with the metric name as the field key.
+	NameAsMeasurement bool
+}
+
+func NewMetric2Influx() *Metric2Influx {
+	return &Metric2Influx{
+		Measurement:       "rocksdb",
+		NameAsMeasurement: false,
+	}
+}
+
+// WriteFile writes metrics to the given path; "-" writes to stdout.
+func (w *Metric2Influx) WriteFile(metrics []Metric, path string) error {
+	if path == "-" {
+		return w.Write(metrics, os.Stdout)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("open influx output: %w", err)
+	}
+	defer f.Close()
+	return w.Write(metrics, f)
+}
+
+// Write emits one line per metric. Timestamps are in nanoseconds; zero times are omitted.
+func (w *Metric2Influx) Write(metrics []Metric, out io.Writer) error {
+	measurement := w.Measurement
+	if measurement == "" {
+		measurement = "rocksdb"
+	}
+	bw := bufio.NewWriter(out)
+	for _, m := range metrics {
+		base, cf := MetricCF(m)
+		field := "value"
+		if w.NameAsMeasurement {
+			bw.WriteString(escapeInfluxMeasurement(base))
+		} else {
+			bw.WriteString(escapeInfluxMeasurement(measurement))
+			field = base
+		}
+		tags := make(map[string]string, len(m.Labels)+2)
+		for k, v := range m.Labels {
+			tags[k] = v
+		}
+		if cf != "" {
+			tags["cf"] = cf
+		}
+		if m.SourceType != "" {
+			tags["source"] = string(m.SourceType)
+		}
+		// Line protocol recommends tags sorted by key
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if tags[k] == "" {
+				continue
+			}
+			bw.WriteString("," + escapeInfluxKey(k) + "=" + escapeInfluxKey(tags[k]))
+		}
+		bw.WriteString(" " + escapeInfluxKey(field) + "=" + strconv.FormatFloat(m.Value, 'g', -1, 64))
+		if !m.StartTime.IsZero() {
+			bw.WriteString(" " + strconv.FormatInt(m.StartTime.UnixNano(), 10))
+		}
+		bw.WriteString("\n")
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write influx: %w", err)
+	}
+	return nil
+}
+
+// escapeInfluxMeasurement escapes commas and spaces in a measurement name.
+func escapeInfluxMeasurement(s string) string {
+	s = strings.ReplaceAll(s, ",", `\,`)
+	s = strings.ReplaceAll(s, " ", `\ `)
+	return s
+}
+
+// escapeInfluxKey escapes commas, equals signs and spaces in tag keys/values and field keys.
+func escapeInfluxKey(s string) string {
+	s = strings.ReplaceAll(s, ",", `\,`)
+	s = strings.ReplaceAll(s, "=", `\=`)
+	s = strings.ReplaceAll(s, " ", `\ `)
+	return s
+}
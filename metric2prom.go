@@ -0,0 +1,137 @@
+package logparser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metric2Prom writes []Metric in Prometheus text exposition format:
+//
+//	name{cf="default",source="DUMP"} value timestamp_ms
+//
+// The CF suffix is moved from the name into the cf label.
+type Metric2Prom struct {
+	// Prefix is prepended to every metric name (e.g. "rocksdb_").
+	Prefix string
+}
+
+func NewMetric2Prom() *Metric2Prom {
+	return &Metric2Prom{}
+}
+
+// WriteFile writes metrics to the given path; "-" writes to stdout.
+func (w *Metric2Prom) WriteFile(metrics []Metric, path string) error {
+	if path == "-" {
+		return w.Write(metrics, os.Stdout)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("open prom output: %w", err)
+	}
+	defer f.Close()
+	return w.Write(metrics, f)
+}
+
+// Write emits metrics grouped by name (in first-seen order), each group preceded by a TYPE line.
+// Metric labels become Prometheus labels, plus cf (label or name suffix) and source.
+func (w *Metric2Prom) Write(metrics []Metric, out io.Writer) error {
+	order := make([]string, 0)
+	byName := make(map[string][]string)
+	for _, m := range metrics {
+		base, cf := MetricCF(m)
+		name := sanitizePromName(w.Prefix + base)
+		if _, ok := byName[name]; !ok {
+			order = append(order, name)
+		}
+		labels := make(map[string]string, len(m.Labels)+2)
+		for k, v := range m.Labels {
+			labels[sanitizePromLabel(k)] = v
+		}
+		if cf != "" {
+			labels["cf"] = cf
+		}
+		if m.SourceType != "" {
+			labels["source"] = string(m.SourceType)
+		}
+		keys := make([]string, 0, len(labels))
+		for k := range labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		b.WriteString(name)
+		if len(keys) > 0 {
+			b.WriteByte('{')
+			for i, k := range keys {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				b.WriteString(k + `="` + escapePromLabel(labels[k]) + `"`)
+			}
+			b.WriteByte('}')
+		}
+		b.WriteString(" " + strconv.FormatFloat(m.Value, 'g', -1, 64))
+		if !m.StartTime.IsZero() {
+			b.WriteString(" " + strconv.FormatInt(m.StartTime.UnixMilli(), 10))
+		}
+		byName[name] = append(byName[name], b.String())
+	}
+	bw := bufio.NewWriter(out)
+	for _, name := range order {
+		fmt.Fprintf(bw, "# TYPE %s gauge\n", name)
+		for _, line := range byName[name] {
+			bw.WriteString(line + "\n")
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write prom: %w", err)
+	}
+	return nil
+}
+
+// sanitizePromName maps a metric name onto [a-zA-Z_:][a-zA-Z0-9_:]*.
+func sanitizePromName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		ok := c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+		if !ok {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+// sanitizePromLabel maps a label name onto [a-zA-Z_][a-zA-Z0-9_]*. Names starting with "__" are
+// reserved for Prometheus internals, so a leading run of underscores collapses to one.
+func sanitizePromLabel(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		ok := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+		if !ok {
+			b[i] = '_'
+		}
+	}
+	out := string(b)
+	if strings.HasPrefix(out, "__") {
+		out = "_" + strings.TrimLeft(out, "_")
+	}
+	if out == "" {
+		return "_"
+	}
+	return out
+}
+
+func escapePromLabel(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
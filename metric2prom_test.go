@@ -0,0 +1,45 @@
+package logparser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitizePromLabel(t *testing.T) {
+	cases := map[string]string{
+		"cf":         "cf",
+		"db:path":    "db_path",
+		"__name__":   "_name__",
+		"___x":       "_x",
+		"_ok":        "_ok",
+		"1st":        "_st",
+		"a-b.c":      "a_b_c",
+		"":           "_",
+		"__":         "_",
+		"job:id_2":   "job_id_2",
+		"Level0":     "Level0",
+		"kind/files": "kind_files",
+	}
+	for in, want := range cases {
+		if got := sanitizePromLabel(in); got != want {
+			t.Errorf("sanitizePromLabel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMetric2PromLabelNames(t *testing.T) {
+	at := time.Date(2025, 11, 30, 3, 0, 0, 0, time.UTC)
+	ms := []Metric{{
+		Name: "job:Count", StartTime: at, Value: 2, SourceType: LogTypeEvents,
+		Labels: map[string]string{"db:path": "/data", "__name__": "x"},
+	}}
+	var b strings.Builder
+	if err := NewMetric2Prom().Write(ms, &b); err != nil {
+		t.Fatal(err)
+	}
+	want := `job:Count{_name__="x",db_path="/data",source="EVENTS"} 2 1764471600000`
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("got\n%s\nwant a line %s", b.String(), want)
+	}
+}
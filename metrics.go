@@ -2,6 +2,7 @@ package logparser
 
 import (
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -12,21 +13,131 @@ import (
 // - StartTime: the LogItem start time
 // - Name: metric name (e.g., "DB_Ingest_MB", "BC_Hit_Cum", "Level0_Files")
 // - Value: numeric value
+// - Labels: optional dimensions (cf, level, command); names keep their CF suffix for existing configs
 type Metric struct {
 	SourceType LogType
 	StartTime  time.Time
 	Name       string
 	Value      float64
+	Labels     map[string]string
+}
+
+// KnownCFs lists column family names recognized as metric name suffixes (e.g. "Flush_GB_default").
+// Any other "_<name>_cf" suffix is recognized as a CF as well.
+var KnownCFs = []string{"default", "data_cf", "score_cf"}
+
+var reCFSuffix = regexp.MustCompile(`_([A-Za-z0-9]+_cf)$`)
+
+// SplitMetricCF splits a metric name into its base name and CF suffix, if any.
+// "Flush_GB_default" -> ("Flush_GB", "default"); "DB_Ingest_MB" -> ("DB_Ingest_MB", "").
+func SplitMetricCF(name string) (string, string) {
+	for _, cf := range KnownCFs {
+		if strings.HasSuffix(name, "_"+cf) && len(name) > len(cf)+1 {
+			return name[:len(name)-len(cf)-1], cf
+		}
+	}
+	if m := reCFSuffix.FindStringSubmatch(name); len(m) == 2 {
+		return name[:len(name)-len(m[0])], m[1]
+	}
+	return name, ""
+}
+
+// MetricCF returns the metric's base name and CF, preferring the "cf" label and falling back
+// to the name suffix for metrics without labels (e.g. aggregated or expression output).
+func MetricCF(m Metric) (string, string) {
+	if cf := m.Labels["cf"]; cf != "" {
+		if strings.HasSuffix(m.Name, "_"+cf) {
+			return m.Name[:len(m.Name)-len(cf)-1], cf
+		}
+		return m.Name, cf
+	}
+	return SplitMetricCF(m.Name)
+}
+
+// labelsKey renders labels as a stable "k=v,k=v" string (sorted by key) for grouping keys.
+func labelsKey(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k + "=" + labels[k])
+	}
+	return b.String()
 }
 
 // RocksDMetricParser extracts useful metrics from a LogItem.
 // Provide Parse(item) to get all metrics for that item.
-type RocksDMetricParser struct{}
+type RocksDMetricParser struct {
+	// EventBytesUnit ("KB", "MB" or "GB") additionally emits EVENT_LOG byte fields converted to
+	// that unit as <name>_<unit> (e.g. Event_flush_finished_file_size_MB), matching the scale
+	// of DUMP metrics. The raw byte metric is always emitted. Empty disables.
+	EventBytesUnit string
+	// Aliases rewrites variant names to a canonical one before emitting: event names in
+	// EVENT_LOG (e.g. "trival_move" -> "trivial_move") and base metric names in DUMP.
+	// nil uses DefaultMetricAliases; an empty map disables aliasing.
+	Aliases map[string]string
+	// IncludeNames and ExcludeNames filter metrics by full name (exact or glob, case-insensitive,
+	// e.g. "Flush_GB*") as they are emitted: a name is kept when it matches any IncludeNames
+	// (or IncludeNames is empty) and no ExcludeNames. Empty lists keep everything.
+	IncludeNames []string
+	ExcludeNames []string
+}
+
+// DefaultMetricAliases maps name variants seen across RocksDB versions to canonical names.
+var DefaultMetricAliases = map[string]string{
+	"trival_move": "trivial_move",
+}
+
+// alias returns the canonical name for name, or name itself when it has no alias.
+func (mp *RocksDMetricParser) alias(name string) string {
+	aliases := mp.Aliases
+	if aliases == nil {
+		aliases = DefaultMetricAliases
+	}
+	if a, ok := aliases[name]; ok {
+		return a
+	}
+	return name
+}
 
 func NewRocksDMetricParser() *RocksDMetricParser { return &RocksDMetricParser{} }
 
+// keepMetricName reports whether name passes the include/exclude name globs.
+func keepMetricName(name string, include, exclude []string) bool {
+	for _, p := range exclude {
+		if matchNameGlob(p, name) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, p := range include {
+		if matchNameGlob(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
 // Parse returns all metrics extracted from the given item.
 func (mp *RocksDMetricParser) Parse(item LogItem) []Metric {
+	return mp.ParseLines(item.Lines, item.Type, item.StartTime)
+}
+
+// ParseLines extracts metrics from raw item lines of a known type, stamped with at.
+// It is Parse without a LogItem, e.g. for lines from a fixture or another source.
+func (mp *RocksDMetricParser) ParseLines(lines []string, t LogType, at time.Time) []Metric {
+	item := LogItem{StartTime: at, Lines: lines, Type: t}
 	switch item.Type {
 	case LogTypeStatistics:
 		return mp.parseStatistics(item)
@@ -48,6 +159,8 @@ var (
 		"File_Opens_Cum":   regexp.MustCompile(`^rocksdb\.no\.file\.opens\s+COUNT\s*:\s*([0-9]+)`),
 		"DB_Seek_Cum":      regexp.MustCompile(`^rocksdb\.number\.db\.seek\s+COUNT\s*:\s*([0-9]+)`),
 		"DB_Next_Cum":      regexp.MustCompile(`^rocksdb\.number\.db\.next\s+COUNT\s*:\s*([0-9]+)`),
+		// cumulative microseconds writers spent stalled
+		"Write_Stall_Cum": regexp.MustCompile(`^rocksdb\.stall\.micros\s+COUNT\s*:\s*([0-9]+)`),
 	}
 	reP99Num = regexp.MustCompile(`P99\s*:\s*([0-9.]+)`)
 )
@@ -56,6 +169,9 @@ func (mp *RocksDMetricParser) parseStatistics(item LogItem) []Metric {
 	var out []Metric
 	seen := map[string]struct{}{} // key: name|cf
 	add := func(name string, v float64) {
+		if !keepMetricName(name, mp.IncludeNames, mp.ExcludeNames) {
+			return
+		}
 		key := name
 		if _, ok := seen[key]; ok {
 			return // keep first occurrence
@@ -119,6 +235,10 @@ func (mp *RocksDMetricParser) parseStatistics(item LogItem) []Metric {
 			if v, ok := pickP99(s); ok {
 				add("DB_Seek_P99_us", v)
 			}
+		case strings.HasPrefix(s, "rocksdb.db.write.stall"):
+			if v, ok := pickP99(s); ok {
+				add("Write_Stall_P99_us", v)
+			}
 		}
 	}
 	return out
@@ -140,6 +260,11 @@ func pickP99(line string) (float64, bool) {
 var (
 	// Interval writes: ... ingest: 0.02 MB, 0.00 MB/s
 	reIntervalWrites = regexp.MustCompile(`^Interval writes:.*ingest:\s*([0-9.]+)\s*(KB|MB|GB),\s*([0-9.]+)\s*MB/s`)
+	// Cumulative writes: 1000 writes, 2000 keys, ... (counts may carry a K/M/G/T suffix); the
+	// Interval line also matches reIntervalWrites for its ingest figures.
+	reWritesCount = regexp.MustCompile(`^(Cumulative|Interval) writes:\s*([0-9.]+)([KMGT]?) writes,\s*([0-9.]+)([KMGT]?) keys`)
+	// Interval stall: 00:00:1.500 H:M:S, 0.2 percent (also Cumulative stall)
+	reStall = regexp.MustCompile(`^(Cumulative|Interval) stall:\s*([0-9]+):([0-9]+):([0-9.]+) H:M:S,\s*([0-9.]+) percent`)
 	// Interval WAL: ... written: 0.00 MB, 0.00 MB/s
 	reIntervalWAL = regexp.MustCompile(`^Interval WAL:.*written:\s*([0-9.]+)\s*(KB|MB|GB),\s*([0-9.]+)\s*MB/s`)
 	// Uptime(secs): total, interval
@@ -158,31 +283,98 @@ var (
 	reIntComp = regexp.MustCompile(`^Interval compaction:\s*([0-9.]+)\s*GB write,\s*([0-9.]+)\s*MB/s write,\s*([0-9.]+)\s*GB read,\s*([0-9.]+)\s*MB/s read,\s*([0-9.]+)\s*seconds`)
 	// Per-level line: Lx a/b Size Unit Score Read(GB) Rn(GB) Rnp1(GB) Write(GB) Wnew(GB) Moved(GB) W-Amp Rd(MB/s) Wr(MB/s) Comp(sec) Comp(cnt) Avg(sec) KeyIn KeyDrop
 	reLevel = regexp.MustCompile(`^L([0-6])\s+([0-9]+)/([0-9]+)\s+([0-9.]+)\s+(KB|MB|GB)`)
+	// Totals row of the compaction stats table: Sum a/b Size Unit Score ... (same columns as Lx)
+	reLevelSum = regexp.MustCompile(`^Sum\s+([0-9]+)/([0-9]+)\s+([0-9.]+)\s+(KB|MB|GB)`)
+	// Compaction stats table header: Level Files Size Score ... Comp(cnt) Avg(sec) ...
+	reLevelHdr = regexp.MustCompile(`^Level\s+Files\s+Size\b`)
 	// Compaction stats header: ** Compaction Stats [cf] **
 	reCompStatsHdr = regexp.MustCompile(`^\*\* Compaction Stats \[([^\]]+)\] \*\*`)
 	// Histogram header: ** File Read Latency Histogram By Level [cf] **
 	reHistHdr = regexp.MustCompile(`^\*\* File Read Latency Histogram By Level \[([^\]]+)\] \*\*`)
+	// Blob section header: ** Blob file stats [cf] ** (CF optional)
+	reBlobHdr = regexp.MustCompile(`(?i)^\*\* Blob file stats(?: \[([^\]]+)\])? \*\*`)
+	// Blob file count: 3, total size: 0.1 GB, garbage size: 0.0 GB, space amp: 1.0
+	reBlobFiles = regexp.MustCompile(`(?i)^Blob file count:\s*([0-9]+),\s*total size:\s*([0-9.]+)\s*(KB|MB|GB)`)
+	// Any other DUMP sub-section header: ** DB Stats **, ------- DUMPING STATS -------
+	reDumpSection = regexp.MustCompile(`^\*\*.*\*\*$|-{3,}\s*DUMPING STATS\s*-{3,}`)
+	// WAL size: 12.3 MB (also "WAL file size:" / "Total WAL size:")
+	reWALSize = regexp.MustCompile(`(?i)^(?:Total )?WAL (?:file )?size:\s*([0-9.]+)\s*(KB|MB|GB)`)
 )
 
+// defaultLevelCols is the compaction stats table header of recent RocksDB versions, used when
+// a DUMP half lacks the header line.
+var defaultLevelCols = strings.Fields("Level Files Size Score Read(GB) Rn(GB) Rnp1(GB) Write(GB) Wnew(GB) Moved(GB) W-Amp Rd(MB/s) Wr(MB/s) Comp(sec) CompMergeCPU(sec) Comp(cnt) Avg(sec) KeyIn KeyDrop")
+
+// levelColumn returns the value of column name in a compaction stats row, locating it by
+// the table header since columns vary across RocksDB versions. The Size column spans two
+// fields in data rows (value and unit), shifting every later column by one.
+func levelColumn(cols, fields []string, name string) (float64, bool) {
+	for i, c := range cols {
+		if c != name {
+			continue
+		}
+		if i > 2 {
+			i++
+		}
+		if i >= len(fields) {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(fields[i], 64)
+		return v, err == nil
+	}
+	return 0, false
+}
+
+// parseDump is line-driven and does not require the DUMPING STATS head, so a stats-only
+// half (an orphan [/db_impl.cc:670] item after log rotation) yields the same metrics.
 func (mp *RocksDMetricParser) parseDump(item LogItem) []Metric {
 	var out []Metric
 	seen := map[string]struct{}{} // key: name|cf
-	add := func(name string, v float64, cf string) {
+	// kv are extra label pairs (key, value, ...) attached alongside the cf label.
+	add := func(name string, v float64, cf string, kv ...string) {
+		name = mp.alias(name)
 		suffix := ""
 		if cf != "" {
 			suffix = "_" + cf
 		}
 		fullName := name + suffix
+		if !keepMetricName(fullName, mp.IncludeNames, mp.ExcludeNames) {
+			return
+		}
 		key := fullName
 		if _, ok := seen[key]; ok {
 			return // keep first occurrence
 		}
 		seen[key] = struct{}{}
-		out = append(out, Metric{SourceType: item.Type, StartTime: item.StartTime, Name: fullName, Value: v})
+		var labels map[string]string
+		if cf != "" || len(kv) > 0 {
+			labels = make(map[string]string, 1+len(kv)/2)
+			if cf != "" {
+				labels["cf"] = cf
+			}
+			for i := 0; i+1 < len(kv); i += 2 {
+				labels[kv[i]] = kv[i+1]
+			}
+		}
+		out = append(out, Metric{SourceType: item.Type, StartTime: item.StartTime, Name: fullName, Value: v, Labels: labels})
+	}
+	addIntervalCum := func(name, cum, intv string, cf string) {
+		c, _ := strconv.ParseFloat(cum, 64)
+		v, _ := strconv.ParseFloat(intv, 64)
+		add(name, v, cf)
+		add(name+"_Interval", v, cf)
+		add(name+"_Cumulative", c, cf)
 	}
 	currentCF := "" // "", "default", "data_cf", etc.
+	levelCols := defaultLevelCols
+	// DB-wide Uptime(secs) of this dump, the divisor of the writes/keys rates
+	var uptimeTotal, uptimeIntv float64
 	for _, line := range item.Lines {
 		s := strings.TrimSpace(line)
+		if reLevelHdr.MatchString(s) {
+			levelCols = strings.Fields(s)
+			continue
+		}
 		// CF context detection
 		if m := reCompStatsHdr.FindStringSubmatch(s); len(m) == 2 {
 			currentCF = strings.ToLower(m[1])
@@ -193,6 +385,58 @@ func (mp *RocksDMetricParser) parseDump(item LogItem) []Metric {
 			currentCF = ""
 			continue
 		}
+		if m := reBlobHdr.FindStringSubmatch(s); len(m) == 2 {
+			// blob section: scoped to its own CF, or DB-wide when the header has none
+			currentCF = strings.ToLower(m[1])
+			continue
+		}
+		if reDumpSection.MatchString(s) {
+			// a new DB-wide sub-section: drop the CF of the previous block
+			currentCF = ""
+			continue
+		}
+		if m := reBlobFiles.FindStringSubmatch(s); len(m) == 4 {
+			files, _ := strconv.ParseFloat(m[1], 64)
+			add("Blob_Files", files, currentCF)
+			add("Blob_Size_GB", toMB(m[2], m[3])/1024.0, currentCF)
+			continue
+		}
+		if m := reWALSize.FindStringSubmatch(s); len(m) == 3 {
+			add("WAL_Size_MB", toMB(m[1], m[2]), currentCF)
+			continue
+		}
+		// Cumulative/Interval writes: counts and per-second rates over the matching uptime
+		if m := reWritesCount.FindStringSubmatch(s); len(m) == 6 {
+			writes := humanCount(m[2], m[3])
+			keys := humanCount(m[4], m[5])
+			secs := uptimeIntv
+			if m[1] == "Cumulative" {
+				secs = uptimeTotal
+			}
+			add("Writes_"+m[1], writes, "")
+			add("Keys_Written_"+m[1], keys, "")
+			if secs > 0 {
+				add("Writes_Per_Sec_"+m[1], writes/secs, "")
+				add("Keys_Per_Sec_"+m[1], keys/secs, "")
+				if m[1] == "Interval" {
+					add("Writes_Per_Sec", writes/secs, "")
+					add("Keys_Per_Sec", keys/secs, "")
+				}
+			}
+			if m[1] == "Cumulative" {
+				continue
+			}
+			// the Interval line still carries the ingest figures below
+		}
+		if m := reStall.FindStringSubmatch(s); len(m) == 6 {
+			h, _ := strconv.ParseFloat(m[2], 64)
+			mi, _ := strconv.ParseFloat(m[3], 64)
+			sec, _ := strconv.ParseFloat(m[4], 64)
+			pct, _ := strconv.ParseFloat(m[5], 64)
+			add("Stall_Sec_"+m[1], h*3600+mi*60+sec, "")
+			add("Stall_Pct_"+m[1], pct, "")
+			continue
+		}
 		// Interval writes
 		if m := reIntervalWrites.FindStringSubmatch(s); len(m) == 4 {
 			ingMB := toMB(m[1], m[2])
@@ -212,28 +456,29 @@ func (mp *RocksDMetricParser) parseDump(item LogItem) []Metric {
 		// Uptime(secs)
 		if m := reUptime.FindStringSubmatch(s); len(m) == 3 {
 			intv, _ := strconv.ParseFloat(m[2], 64)
+			if currentCF == "" {
+				uptimeTotal, _ = strconv.ParseFloat(m[1], 64)
+				uptimeIntv = intv
+			}
 			add("Uptime_Sec", intv, currentCF)
 			continue
 		}
-		// Flush/AddFile counters (interval)
+		// Flush/AddFile counters: emit both halves as <Name>_Interval / <Name>_Cumulative.
+		// The bare <Name> is kept as an alias of the interval value for existing chart configs.
 		if m := reFlushGB.FindStringSubmatch(s); len(m) == 3 {
-			v, _ := strconv.ParseFloat(m[2], 64)
-			add("Flush_GB", v, currentCF)
+			addIntervalCum("Flush_GB", m[1], m[2], currentCF)
 			continue
 		}
 		if m := reAddFileGB.FindStringSubmatch(s); len(m) == 3 {
-			v, _ := strconv.ParseFloat(m[2], 64)
-			add("Add_GB", v, currentCF)
+			addIntervalCum("Add_GB", m[1], m[2], currentCF)
 			continue
 		}
 		if m := reAddTotalFiles.FindStringSubmatch(s); len(m) == 3 {
-			v, _ := strconv.ParseFloat(m[2], 64)
-			add("Add_TotalFiles", v, currentCF)
+			addIntervalCum("Add_TotalFiles", m[1], m[2], currentCF)
 			continue
 		}
 		if m := reAddL0Files.FindStringSubmatch(s); len(m) == 3 {
-			v, _ := strconv.ParseFloat(m[2], 64)
-			add("Add_L0Files", v, currentCF)
+			addIntervalCum("Add_L0Files", m[1], m[2], currentCF)
 			continue
 		}
 		// Compaction summaries
@@ -248,6 +493,12 @@ func (mp *RocksDMetricParser) parseDump(item LogItem) []Metric {
 			add("Cum_Compaction_Read_GB", rgb, currentCF)
 			add("Cum_Compaction_Read_MBps", rmbps, currentCF)
 			add("Cum_Compaction_Sec", sec, currentCF)
+			// Same values under the <Name>_Cumulative convention
+			add("Compaction_Write_GB_Cumulative", wgb, currentCF)
+			add("Compaction_Write_MBps_Cumulative", wmbps, currentCF)
+			add("Compaction_Read_GB_Cumulative", rgb, currentCF)
+			add("Compaction_Read_MBps_Cumulative", rmbps, currentCF)
+			add("Compaction_Sec_Cumulative", sec, currentCF)
 			continue
 		}
 		if m := reIntComp.FindStringSubmatch(s); len(m) == 6 {
@@ -261,6 +512,11 @@ func (mp *RocksDMetricParser) parseDump(item LogItem) []Metric {
 			add("Compaction_Read_GB", rgb, currentCF)
 			add("Compaction_Read_MBps", rmbps, currentCF)
 			add("Compaction_Sec", sec, currentCF)
+			add("Compaction_Write_GB_Interval", wgb, currentCF)
+			add("Compaction_Write_MBps_Interval", wmbps, currentCF)
+			add("Compaction_Read_GB_Interval", rgb, currentCF)
+			add("Compaction_Read_MBps_Interval", rmbps, currentCF)
+			add("Compaction_Sec_Interval", sec, currentCF)
 			continue
 		}
 		// Per-level key metrics (files/size)
@@ -268,9 +524,33 @@ func (mp *RocksDMetricParser) parseDump(item LogItem) []Metric {
 			lvl := m[1]
 			files, _ := strconv.ParseFloat(m[2], 64)
 			sizeMB := toMB(m[4], m[5])
-			add("Level"+lvl+"_Files", files, currentCF)
-			add("Level"+lvl+"_Size_MB", sizeMB, currentCF)
-			// Additional columns (W-Amp, Rd(MB/s), etc.) can be parsed if needed with a richer regex.
+			add("Level"+lvl+"_Files", files, currentCF, "level", lvl)
+			add("Level"+lvl+"_Size_MB", sizeMB, currentCF, "level", lvl)
+			fields := strings.Fields(s)
+			if v, ok := levelColumn(levelCols, fields, "Comp(cnt)"); ok {
+				add("Level"+lvl+"_Comp_Cnt", v, currentCF, "level", lvl)
+			}
+			if v, ok := levelColumn(levelCols, fields, "Avg(sec)"); ok {
+				add("Level"+lvl+"_Avg_Sec", v, currentCF, "level", lvl)
+			}
+			continue
+		}
+		if m := reLevelSum.FindStringSubmatch(s); len(m) == 5 {
+			files, _ := strconv.ParseFloat(m[1], 64)
+			add("Total_Files", files, currentCF)
+			add("Total_Size_MB", toMB(m[3], m[4]), currentCF)
+			fields := strings.Fields(s)
+			for _, c := range []struct{ col, name string }{
+				{"Read(GB)", "Total_Read_GB"},
+				{"Write(GB)", "Total_Write_GB"},
+				{"W-Amp", "Total_WAmp"},
+				{"Comp(sec)", "Total_Comp_Sec"},
+				{"Comp(cnt)", "Total_Comp_Cnt"},
+			} {
+				if v, ok := levelColumn(levelCols, fields, c.col); ok {
+					add(c.name, v, currentCF)
+				}
+			}
 			continue
 		}
 	}
@@ -281,6 +561,8 @@ func (mp *RocksDMetricParser) parseDump(item LogItem) []Metric {
 var (
 	reEventName = regexp.MustCompile(`"event"\s*:\s*"([^"]+)"`)
 	reCFName    = regexp.MustCompile(`"cf_name"\s*:\s*"([^"]+)"`)
+	// Event's own timestamp (unix micros); preferred over the LOG line head time
+	reTimeMicros = regexp.MustCompile(`"time_micros"\s*:\s*([0-9]+)`)
 	// Common numeric fields in EVENT_LOG
 	reNumFields = map[string]*regexp.Regexp{
 		"bytes_written":  regexp.MustCompile(`"bytes_written"\s*:\s*([0-9]+)`),
@@ -293,6 +575,15 @@ var (
 		"tables":         regexp.MustCompile(`"tables"\s*:\s*([0-9]+)`),
 		"files":          regexp.MustCompile(`"files"\s*:\s*([0-9]+)`),
 	}
+	// EVENT_LOG fields holding byte counts (converted by EventBytesUnit)
+	eventByteFields = map[string]bool{
+		"bytes_written":  true,
+		"file_size":      true,
+		"bytes":          true,
+		"size":           true,
+		"data_size":      true,
+		"wal_file_bytes": true,
+	}
 	// Non-JSON stall notices
 	rePendingStall = regexp.MustCompile(`(?i)(Stalling|Stopping) writes because of estimated pending compaction bytes`)
 )
@@ -300,33 +591,50 @@ var (
 func (mp *RocksDMetricParser) parseEvents(item LogItem) []Metric {
 	var out []Metric
 	seen := map[string]struct{}{} // key: name|cf
-	add := func(name string, v float64, cf string) {
+	// at is the event's own time (time_micros) when present, else the item head time.
+	add := func(name string, v float64, cf string, at time.Time) {
+		var labels map[string]string
 		if cf != "" {
-			name = name + "_" + strings.ToLower(cf)
+			cf = strings.ToLower(cf)
+			name = name + "_" + cf
+			labels = map[string]string{"cf": cf}
+		}
+		if !keepMetricName(name, mp.IncludeNames, mp.ExcludeNames) {
+			return
 		}
 		key := name
 		if _, ok := seen[key]; ok {
 			return
 		}
 		seen[key] = struct{}{}
-		out = append(out, Metric{SourceType: item.Type, StartTime: item.StartTime, Name: name, Value: v})
+		out = append(out, Metric{SourceType: item.Type, StartTime: at, Name: name, Value: v, Labels: labels})
 	}
 
 	for _, line := range item.Lines {
 		s := strings.TrimSpace(line)
+		at := item.StartTime
+		if m := reTimeMicros.FindStringSubmatch(s); len(m) == 2 {
+			if us, err := strconv.ParseInt(m[1], 10, 64); err == nil && us > 0 {
+				at = time.UnixMicro(us)
+			}
+		}
 		cf := ""
 		if m := reCFName.FindStringSubmatch(s); len(m) == 2 {
 			cf = strings.ToLower(m[1])
 		}
 		// Count the event
 		if m := reEventName.FindStringSubmatch(s); len(m) == 2 {
-			ev := m[1]
-			add("Event_"+canonicalizeMetricName(ev)+"_Count", 1, cf)
+			ev := mp.alias(canonicalizeMetricName(m[1]))
+			add("Event_"+ev+"_Count", 1, cf, at)
 			// Extract common numeric fields for this event
 			for fname, re := range reNumFields {
 				if n := re.FindStringSubmatch(s); len(n) == 2 {
 					if v, err := strconv.ParseFloat(n[1], 64); err == nil {
-						add("Event_"+canonicalizeMetricName(ev)+"_"+canonicalizeFieldName(fname), v, cf)
+						name := "Event_" + ev + "_" + canonicalizeFieldName(fname)
+						add(name, v, cf, at)
+						if unit := bytesUnit(mp.EventBytesUnit); unit != "" && eventByteFields[fname] {
+							add(name+"_"+unit, ConvertUnit(v, "B", unit), cf, at)
+						}
 					}
 				}
 			}
@@ -334,7 +642,7 @@ func (mp *RocksDMetricParser) parseEvents(item LogItem) []Metric {
 		}
 		// Non-JSON stall events
 		if rePendingStall.MatchString(s) {
-			add("Event_PendingCompactionBytes_Stall_Count", 1, cf)
+			add("Event_PendingCompactionBytes_Stall_Count", 1, cf, at)
 			continue
 		}
 	}
@@ -369,23 +677,94 @@ func (mp *RocksDMetricParser) parseReadLatency(item LogItem) []Metric {
 	return nil
 }
 
-func toMB(vs string, unit string) float64 {
-	v, _ := strconv.ParseFloat(vs, 64)
-	switch strings.ToUpper(unit) {
-	case "GB":
-		return v * 1024.0
-	case "KB":
-		return v / 1024.0
+// bytesUnit returns the canonical name of a byte unit (KB, MB or GB), or "" for an unknown
+// or empty unit.
+func bytesUnit(unit string) string {
+	switch u := strings.ToUpper(strings.TrimSpace(unit)); u {
+	case "KB", "MB", "GB":
+		return u
 	default:
-		return v
+		return ""
+	}
+}
+
+// unitScale gives each supported unit's dimension and its size in the dimension's base unit
+// (bytes or seconds). Byte units are 1024-based, matching RocksDB's LOG output.
+var unitScale = map[string]struct {
+	dim   string
+	scale float64
+}{
+	"B":   {"bytes", 1},
+	"KB":  {"bytes", 1024},
+	"MB":  {"bytes", 1024 * 1024},
+	"GB":  {"bytes", 1024 * 1024 * 1024},
+	"S":   {"time", 1},
+	"SEC": {"time", 1},
+	"MS":  {"time", 1e-3},
+	"US":  {"time", 1e-6},
+}
+
+// ConvertUnit converts value from one unit to another: bytes (B, KB, MB, GB) or durations
+// (s/sec, ms, us). Units are case-insensitive. Unknown units or a conversion across dimensions
+// return value unchanged.
+func ConvertUnit(value float64, from, to string) float64 {
+	f, ok1 := unitScale[strings.ToUpper(strings.TrimSpace(from))]
+	t, ok2 := unitScale[strings.ToUpper(strings.TrimSpace(to))]
+	if !ok1 || !ok2 || f.dim != t.dim {
+		return value
 	}
+	return value * f.scale / t.scale
+}
+
+// humanCount parses a RocksDB human-readable count such as "12K" (vs "12", suffix "K");
+// suffixes are decimal as in NumberToHumanString.
+func humanCount(vs, suffix string) float64 {
+	v, _ := strconv.ParseFloat(vs, 64)
+	switch suffix {
+	case "K":
+		v *= 1e3
+	case "M":
+		v *= 1e6
+	case "G":
+		v *= 1e9
+	case "T":
+		v *= 1e12
+	}
+	return v
+}
+
+func toMB(vs string, unit string) float64 {
+	v, _ := strconv.ParseFloat(vs, 64)
+	return ConvertUnit(v, unit, "MB")
 }
 
 // ===== PIKA SLOWLOG metrics from LogItem =====
-type PikaSlowMetricParser struct{}
+type PikaSlowMetricParser struct {
+	// CommandPatterns are extra command matchers tried after the built-in ones; capture
+	// group 1 is the command name. Pair with PikaSlowLogItemParser.HeadPatterns.
+	CommandPatterns []*regexp.Regexp
+	// IncludeNames and ExcludeNames filter the emitted Slow_Command_<CMD> names as in
+	// RocksDMetricParser.
+	IncludeNames []string
+	ExcludeNames []string
+}
 
 func NewPikaSlowMetricParser() *PikaSlowMetricParser { return &PikaSlowMetricParser{} }
 
+// matchCommandPatterns returns the upper-cased command captured by the first CommandPatterns
+// match in lines, or "".
+func (sp *PikaSlowMetricParser) matchCommandPatterns(lines []string) string {
+	for _, line := range lines {
+		s := strings.TrimSpace(line)
+		for _, re := range sp.CommandPatterns {
+			if m := re.FindStringSubmatch(s); len(m) >= 2 && m[1] != "" {
+				return strings.ToUpper(strings.TrimSpace(m[1]))
+			}
+		}
+	}
+	return ""
+}
+
 var (
 	reSlowCmdQuoted = regexp.MustCompile(`(?i)\bcommand\s*:\s*\"([^\"]+)\"`)
 	reSlowCmdShort  = regexp.MustCompile(`(?i)\bcmd\s*:\s*([a-z_]+)`)
@@ -395,6 +774,12 @@ var (
 // Parse converts a SLOWLOG LogItem into one or more metrics.
 // Current rule: emit a single count metric per item: Slow_Command_<CMD>=1.
 func (sp *PikaSlowMetricParser) Parse(item LogItem) []Metric {
+	return sp.ParseLines(item.Lines, item.Type, item.StartTime)
+}
+
+// ParseLines is Parse over raw item lines of a known type, stamped with at.
+func (sp *PikaSlowMetricParser) ParseLines(lines []string, t LogType, at time.Time) []Metric {
+	item := LogItem{StartTime: at, Lines: lines, Type: t}
 	if item.Type != LogTypeSlowLog {
 		return nil
 	}
@@ -414,16 +799,200 @@ func (sp *PikaSlowMetricParser) Parse(item LogItem) []Metric {
 			break
 		}
 	}
+	if cmd == "" {
+		cmd = sp.matchCommandPatterns(item.Lines)
+	}
 	if cmd == "" {
 		return nil
 	}
 	name := "Slow_Command_" + cmd
+	if !keepMetricName(name, sp.IncludeNames, sp.ExcludeNames) {
+		return nil
+	}
 	return []Metric{{
 		SourceType: item.Type,
 		StartTime:  item.StartTime,
 		Name:       name,
 		Value:      1,
+		Labels:     map[string]string{"command": cmd},
 	}}
 }
 
+// FilterByCF keeps only metrics belonging to column family cf (by "cf" label or name suffix).
+// Metrics without a CF are dropped. An empty cf returns the input unchanged.
+func FilterByCF(metrics []Metric, cf string) []Metric {
+	cf = strings.ToLower(strings.TrimSpace(cf))
+	if cf == "" {
+		return metrics
+	}
+	out := make([]Metric, 0, len(metrics))
+	for _, m := range metrics {
+		if _, mcf := MetricCF(m); strings.ToLower(mcf) == cf {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// MergeCF strips the CF (name suffix and "cf" label) from every metric and sums the metrics
+// that then share StartTime, SourceType, Name and labels, turning per-CF series such as
+// Flush_GB_default and Flush_GB_data_cf into one instance-wide Flush_GB. Output keeps the order
+// in which each merged metric first appears.
+func MergeCF(metrics []Metric) []Metric {
+	out := make([]Metric, 0, len(metrics))
+	index := make(map[string]int)
+	for _, m := range metrics {
+		base, cf := MetricCF(m)
+		if cf != "" {
+			m.Name = base
+			if _, ok := m.Labels["cf"]; ok {
+				labels := make(map[string]string, len(m.Labels)-1)
+				for k, v := range m.Labels {
+					if k != "cf" {
+						labels[k] = v
+					}
+				}
+				if len(labels) == 0 {
+					labels = nil
+				}
+				m.Labels = labels
+			}
+		}
+		k := m.StartTime.String() + "|" + string(m.SourceType) + "|" + m.Name + "|" + labelsKey(m.Labels)
+		if i, ok := index[k]; ok {
+			out[i].Value += m.Value
+			continue
+		}
+		index[k] = len(out)
+		out = append(out, m)
+	}
+	return out
+}
 
+// FilterByTime keeps metrics whose StartTime lies within [start, end]. A zero start or end
+// means no bound on that side; metrics without a StartTime are dropped when any bound is set.
+func FilterByTime(metrics []Metric, start, end time.Time) []Metric {
+	if start.IsZero() && end.IsZero() {
+		return metrics
+	}
+	out := make([]Metric, 0, len(metrics))
+	for _, m := range metrics {
+		if m.StartTime.IsZero() {
+			continue
+		}
+		if !start.IsZero() && m.StartTime.Before(start) {
+			continue
+		}
+		if !end.IsZero() && m.StartTime.After(end) {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// SortByTime sorts metrics by StartTime in place; metrics with equal times keep their order.
+func SortByTime(metrics []Metric) {
+	sort.SliceStable(metrics, func(i, j int) bool { return metrics[i].StartTime.Before(metrics[j].StartTime) })
+}
+
+// GroupByName groups metrics by Name, keeping the input order within each group. Metrics
+// without a StartTime are skipped.
+func GroupByName(metrics []Metric) map[string][]Metric {
+	return GroupBySeriesKey(metrics, nil)
+}
+
+// GroupBySeriesKey groups metrics by Name plus the keys dimensions, keyed by seriesKey: "source"
+// for SourceType, "cf" for the column family (label or name suffix) and any other label key,
+// e.g. "Flush_GB [DUMP cf=data_cf]". No keys groups by Name only. Metrics without a StartTime
+// are skipped and the input order is kept within each group.
+func GroupBySeriesKey(metrics []Metric, keys []string) map[string][]Metric {
+	out := make(map[string][]Metric)
+	for _, m := range metrics {
+		if m.StartTime.IsZero() {
+			continue
+		}
+		k := seriesKey(m, keys)
+		out[k] = append(out[k], m)
+	}
+	return out
+}
+
+// seriesKey returns the series (and legend) name of m: its Name, followed by the values of
+// the keys dimensions in brackets when any are set.
+func seriesKey(m Metric, keys []string) string {
+	if len(keys) == 0 {
+		return m.Name
+	}
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		k = strings.TrimSpace(k)
+		switch strings.ToLower(k) {
+		case "", "name":
+		case "source", "sourcetype":
+			if m.SourceType != "" {
+				parts = append(parts, string(m.SourceType))
+			}
+		case "cf":
+			if _, cf := MetricCF(m); cf != "" {
+				parts = append(parts, "cf="+cf)
+			}
+		default:
+			if v, ok := m.Labels[k]; ok {
+				parts = append(parts, k+"="+v)
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return m.Name
+	}
+	return m.Name + " [" + strings.Join(parts, " ") + "]"
+}
+
+// DropZeroSeries removes every series (Name, SourceType and labels) whose values are all exactly
+// zero. A series with any non-zero reading is kept whole.
+func DropZeroSeries(metrics []Metric) []Metric {
+	nonZero := make(map[string]bool)
+	for _, m := range metrics {
+		if m.Value != 0 {
+			nonZero[m.Name+"|"+string(m.SourceType)+"|"+labelsKey(m.Labels)] = true
+		}
+	}
+	out := make([]Metric, 0, len(metrics))
+	for _, m := range metrics {
+		if nonZero[m.Name+"|"+string(m.SourceType)+"|"+labelsKey(m.Labels)] {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// DedupeMetrics removes exact duplicates on (StartTime, Name, SourceType, Value), e.g. the same
+// STATISTICS or DUMP item read twice from overlapping LOG segments. The first occurrence is kept.
+func DedupeMetrics(metrics []Metric) []Metric {
+	return dedupeMetrics(metrics, func(m Metric) string {
+		return m.StartTime.Format(time.RFC3339Nano) + "|" + m.Name + "|" + string(m.SourceType) + "|" + strconv.FormatFloat(m.Value, 'g', -1, 64)
+	})
+}
+
+// DedupeMetricsByName is the stricter variant of DedupeMetrics: it keeps only the first metric
+// of any (StartTime, Name) collision, regardless of source or value.
+func DedupeMetricsByName(metrics []Metric) []Metric {
+	return dedupeMetrics(metrics, func(m Metric) string {
+		return m.StartTime.Format(time.RFC3339Nano) + "|" + m.Name
+	})
+}
+
+func dedupeMetrics(metrics []Metric, key func(Metric) string) []Metric {
+	seen := make(map[string]struct{}, len(metrics))
+	out := make([]Metric, 0, len(metrics))
+	for _, m := range metrics {
+		k := key(m)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		out = append(out, m)
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package logparser
+
+import (
+	"testing"
+	"time"
+)
+
+func statByName(ms []Metric) map[string]float64 {
+	out := map[string]float64{}
+	for _, m := range ms {
+		out[m.Name] = m.Value
+	}
+	return out
+}
+
+func TestParseStatisticsWriteStall(t *testing.T) {
+	at := time.Date(2025, 11, 30, 3, 0, 0, 0, time.UTC)
+	lines := []string{
+		"2025/11/30-03:00:00.000000 7f1e2b7fe700 [db/db_impl/db_impl.cc:1004] STATISTICS:",
+		" rocksdb.block.cache.miss COUNT : 42",
+		" rocksdb.stall.micros COUNT : 1234567",
+		" rocksdb.db.write.stall P50 : 3.500000 P95 : 40.000000 P99 : 118.250000 P100 : 902.000000 COUNT : 77 SUM : 4096",
+	}
+	ms := NewRocksDMetricParser().ParseLines(lines, LogTypeStatistics, at)
+	got := statByName(ms)
+
+	if v, ok := got["Write_Stall_Cum"]; !ok || v != 1234567 {
+		t.Errorf("Write_Stall_Cum = %v (present %v), want 1234567", v, ok)
+	}
+	if v, ok := got["Write_Stall_P99_us"]; !ok || v != 118.25 {
+		t.Errorf("Write_Stall_P99_us = %v (present %v), want 118.25", v, ok)
+	}
+	if v := got["BC_Miss_Cum"]; v != 42 {
+		t.Errorf("BC_Miss_Cum = %v, want 42", v)
+	}
+	for _, m := range ms {
+		if !m.StartTime.Equal(at) || m.SourceType != LogTypeStatistics {
+			t.Errorf("%s stamped %v/%v, want %v/%v", m.Name, m.StartTime, m.SourceType, at, LogTypeStatistics)
+		}
+	}
+}
+
+func TestParseStatisticsWriteStallMissingP99(t *testing.T) {
+	lines := []string{
+		" rocksdb.db.write.stall P50 : 3.500000 P95 : 40.000000 COUNT : 77 SUM : 4096",
+		" rocksdb.stall.micros COUNT : 0",
+		" rocksdb.stall.micros COUNT : 99",
+	}
+	got := statByName(NewRocksDMetricParser().ParseLines(lines, LogTypeStatistics, time.Time{}))
+	if _, ok := got["Write_Stall_P99_us"]; ok {
+		t.Errorf("Write_Stall_P99_us emitted without a P99 field")
+	}
+	// the first occurrence in an item wins
+	if v, ok := got["Write_Stall_Cum"]; !ok || v != 0 {
+		t.Errorf("Write_Stall_Cum = %v (present %v), want 0", v, ok)
+	}
+}
+
+func TestParseStatisticsWriteStallExcluded(t *testing.T) {
+	mp := NewRocksDMetricParser()
+	mp.ExcludeNames = []string{"Write_Stall_*"}
+	lines := []string{
+		" rocksdb.stall.micros COUNT : 1234567",
+		" rocksdb.db.write.stall P50 : 3.5 P99 : 118.25",
+	}
+	if ms := mp.ParseLines(lines, LogTypeStatistics, time.Time{}); len(ms) != 0 {
+		t.Errorf("got %+v, want nothing", ms)
+	}
+}
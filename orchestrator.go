@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"bytes"
 	"strconv"
@@ -31,6 +32,28 @@ type ChartGroup struct {
 	Agg    string
 	// Optional computed series within this group; evaluated after aggregation on the group's metric set.
 	Exprs  []ExprSpec `json:"exprs"`
+	// Optional column family filter (e.g. "data_cf"); empty keeps all CFs.
+	CF string `json:"cf"`
+	// Optional Top-N: keep only the N busiest series matched by Names, ranked by "peak"
+	// (default) or "total"; TopOther adds an "Other" series summing the rest.
+	TopN     int    `json:"topN"`
+	TopBy    string `json:"topBy"`
+	TopOther bool   `json:"topOther"`
+	// Optional event markers: raw metric names or globs (e.g. "Event_compaction_finished_Count*")
+	// whose sample times are drawn as vertical marker lines.
+	Markers []string `json:"markers"`
+	// Optional Y-axis label; empty labels the axis with the unit shared by all series names
+	// (see DetectYUnit), or leaves it blank when units are mixed.
+	YLabel string `json:"yLabel"`
+	// Optional per-series transform applied after aggregation: value*Scale[name] + Offset[name],
+	// keyed by series name (exact or glob). Transformed series are labeled e.g.
+	// "Level0_Files_Sum (x100)".
+	Scale  map[string]float64 `json:"scale"`
+	Offset map[string]float64 `json:"offset"`
+	// Optional: match Names against the per-CF variants of each name (e.g. "Compaction_Write_GB_Sum"
+	// selects Compaction_Write_GB_default_Sum, Compaction_Write_GB_data_cf_Sum, ...) and draw
+	// them stacked, one band per CF. Metrics without a CF are left out.
+	StackByCF bool `json:"stackByCF"`
 }
 
 // ExprSpec defines a computed metric series Name = Formula
@@ -43,11 +66,142 @@ type ExprSpec struct {
 // ChartOrchestrator renders multiple charts from a single metric stream based on groups.
 type ChartOrchestrator struct {
 	Groups []ChartGroup
+	// Columns is the number of panel columns used by the single-file renders (default 2).
+	Columns int
+	// PanelGap is the spacing in pixels between stacked panels (rows and columns).
+	PanelGap int
+	// DropZeroSeries skips series whose every value is 0 in each rendered chart.
+	DropZeroSeries bool
+	// FailOnEmpty makes a render fail on a group that selects no metrics. By default such
+	// groups are skipped and listed in Skipped.
+	FailOnEmpty bool
+	// Skipped lists the groups (by Out, or Title when Out is empty) skipped as empty by the
+	// last render call.
+	Skipped []string
+	// CaseInsensitiveNames matches exact group Names case-insensitively, like glob patterns
+	// already are (e.g. "bc_hit_cum_sum" selects BC_Hit_Cum_Sum).
+	CaseInsensitiveNames bool
+	// ShowMissing lists group Names (exact or glob) that matched no series in the chart
+	// legend as "(no data)", so typos and empty globs show up on the chart.
+	ShowMissing bool
+	// Template, when set, is the base Dialog of every group (geometry, background, time format,
+	// thresholds, ...): each group renders a copy with its own Title and group settings
+	// applied on top.
+	Template *Dialog
+}
+
+// groupDialog returns the Dialog rendering group g: a copy of Template (or NewDialog) with the
+// group's title, Y label, markers, stacking and missing-series legend applied. gm are the
+// group's metrics before selection (for markers) and series the selected ones.
+func (o *ChartOrchestrator) groupDialog(g ChartGroup, gm, series []Metric, missing []string) *Dialog {
+	dlg := NewDialog()
+	if o.Template != nil {
+		c := *o.Template
+		dlg = &c
+		dlg.EventMarkers = append([]ChartMarker(nil), c.EventMarkers...)
+	}
+	dlg.DropZeroSeries = dlg.DropZeroSeries || o.DropZeroSeries
+	dlg.Stacked = dlg.Stacked || g.StackByCF
+	dlg.EventMarkers = append(dlg.EventMarkers, MarkersFromMetrics(gm, g.Markers)...)
+	if l := groupYLabel(g, series); l != "" {
+		dlg.YLabel = l
+	}
+	dlg.MissingSeries = missing
+	if g.Title != "" {
+		dlg.Title = g.Title
+	} else {
+		dlg.Title = fmt.Sprintf("Metrics: %s", strings.Join(g.Names, ", "))
+	}
+	return dlg
+}
+
+// missingNames returns the group Names that select none of the metrics in selected.
+func (o *ChartOrchestrator) missingNames(g ChartGroup, selected []Metric) []string {
+	if !o.ShowMissing {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(selected))
+	for _, m := range selected {
+		name := m.Name
+		if g.StackByCF {
+			if n, ok := cfStackName(m); ok {
+				name = n
+			}
+		}
+		seen[name] = struct{}{}
+	}
+	var out []string
+	for _, n := range g.Names {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		found := false
+		for name := range seen {
+			if strings.ContainsAny(n, "*?[]") {
+				found = matchNameGlob(n, name)
+			} else {
+				found = foldName(name, o.CaseInsensitiveNames) == foldName(n, o.CaseInsensitiveNames)
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// cfStackName returns m's name with its CF removed, wherever the CF sits before any
+// aggregation suffix ("Flush_GB_default_Sum" -> "Flush_GB_Sum"), and false when m has no CF.
+func cfStackName(m Metric) (string, bool) {
+	_, cf := MetricCF(m)
+	if cf == "" {
+		return "", false
+	}
+	if strings.HasSuffix(m.Name, "_"+cf) {
+		return m.Name[:len(m.Name)-len(cf)-1], true
+	}
+	if i := strings.LastIndex(m.Name, "_"+cf+"_"); i >= 0 {
+		return m.Name[:i] + m.Name[i+len(cf)+1:], true
+	}
+	return m.Name, true
+}
+
+// foldName returns the nameSet key for n: lower-cased when fold is set.
+func foldName(n string, fold bool) string {
+	if fold {
+		return strings.ToLower(n)
+	}
+	return n
+}
+
+// NewChartOrchestrator returns an orchestrator for groups.
+func NewChartOrchestrator(groups []ChartGroup) *ChartOrchestrator {
+	return &ChartOrchestrator{Groups: groups}
+}
+
+// emptyGroup handles a group with no series: it is recorded in Skipped, or with FailOnEmpty
+// an error naming the group is returned.
+func (o *ChartOrchestrator) emptyGroup(g ChartGroup) error {
+	name := g.Out
+	if name == "" {
+		name = g.Title
+	}
+	if o.FailOnEmpty {
+		return fmt.Errorf("chart group %q: no metrics to render", name)
+	}
+	o.Skipped = append(o.Skipped, name)
+	return nil
 }
 
 // RenderAll renders each group to its Out path using Dialog, filtering metrics by Names (exact match).
 func (o *ChartOrchestrator) RenderAll(metrics []Metric) error {
+	o.Skipped = nil
 	for _, g := range o.Groups {
+		gm := FilterByCF(metrics, g.CF)
 		if g.Out == "" {
 			return errors.New("chart group missing Out path")
 		}
@@ -59,19 +213,26 @@ func (o *ChartOrchestrator) RenderAll(metrics []Metric) error {
 				if strings.ContainsAny(n, "*?[]") {
 					patterns = append(patterns, n)
 				} else {
-					nameSet[n] = struct{}{}
+					nameSet[foldName(n, o.CaseInsensitiveNames)] = struct{}{}
 				}
 			}
 		}
-		selected := make([]Metric, 0, len(metrics))
-		for _, m := range metrics {
-			if _, ok := nameSet[m.Name]; ok {
+		selected := make([]Metric, 0, len(gm))
+		for _, m := range gm {
+			name := m.Name
+			if g.StackByCF {
+				var ok bool
+				if name, ok = cfStackName(m); !ok {
+					continue
+				}
+			}
+			if _, ok := nameSet[foldName(name, o.CaseInsensitiveNames)]; ok {
 				selected = append(selected, m)
 				continue
 			}
 			matched := false
 			for _, pat := range patterns {
-				if matchNameGlob(pat, m.Name) {
+				if matchNameGlob(pat, name) {
 					matched = true
 					break
 				}
@@ -84,12 +245,16 @@ func (o *ChartOrchestrator) RenderAll(metrics []Metric) error {
 		if len(g.Exprs) > 0 {
 			selected = append(selected, computeExpressions(selected, g.Exprs)...)
 		}
-		dlg := NewDialog()
-		if g.Title != "" {
-			dlg.Title = g.Title
-		} else {
-			dlg.Title = fmt.Sprintf("Metrics: %s", strings.Join(g.Names, ", "))
+		selected = applyTopN(g, selected)
+		if len(selected) == 0 {
+			if err := o.emptyGroup(g); err != nil {
+				return err
+			}
+			continue
 		}
+		missing := o.missingNames(g, selected)
+		selected = applyScale(g, selected)
+		dlg := o.groupDialog(g, gm, selected, missing)
 		// Ensure folder exists (best-effort)
 		if dir := filepath.Dir(g.Out); dir != "" && dir != "." {
 			_ = ensureDir(dir)
@@ -104,81 +269,201 @@ func (o *ChartOrchestrator) RenderAll(metrics []Metric) error {
 // RenderAllWithAgg renders each group with its own aggregation mode (if provided), otherwise defaultMode.
 // If bucketStep <= 0, no aggregation is applied.
 func (o *ChartOrchestrator) RenderAllWithAgg(metrics []Metric, bucketStep time.Duration, defaultMode AggregateMode, groupBySource bool) error {
+	o.Skipped = nil
 	for _, g := range o.Groups {
+		gm := FilterByCF(metrics, g.CF)
 		if g.Out == "" {
 			return errors.New("chart group missing Out path")
 		}
-		exprMode := strings.ToLower(strings.TrimSpace(g.Agg)) == "expr" || strings.ToLower(strings.TrimSpace(g.Agg)) == "expression"
-		// First aggregate (so names carry suffix _Sum/_Avg/...),
-		// then filter by the configured names.
-		selected := metrics
-		if bucketStep > 0 {
-			mode := PickAggMode(strings.TrimSpace(g.Agg), defaultMode)
-			agg := NewBucketAggregator(bucketStep, mode)
-			agg.GroupBySource = groupBySource
-			selected = agg.Aggregate(selected)
-		}
-		// For expr mode: if expressions specified, replace selection with computed series
-		if exprMode && len(g.Exprs) > 0 {
-			comp := computeExpressions(selected, g.Exprs)
-			if len(comp) > 0 {
-				selected = comp
-			}
-		} else if len(g.Exprs) > 0 {
-			// Non-expr mode: append computed series in addition to base selection
-			selected = append(selected, computeExpressions(selected, g.Exprs)...)
+		filtered := selectGroupWithAgg(g, gm, bucketStep, defaultMode, groupBySource, o.CaseInsensitiveNames)
+		if len(filtered) == 0 {
+			if err := o.emptyGroup(g); err != nil {
+				return err
+			}
+			continue
 		}
-		nameSet := make(map[string]struct{}, len(g.Names))
-		var patterns []string
-		for _, n := range g.Names {
+		missing := o.missingNames(g, filtered)
+		filtered = applyScale(g, filtered)
+		dlg := o.groupDialog(g, gm, filtered, missing)
+		if dir := filepath.Dir(g.Out); dir != "" && dir != "." {
+			_ = ensureDir(dir)
+		}
+		if err := dlg.Render(filtered, g.Out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transformKey returns the value of the first key in m matching name, exact before glob.
+func transformKey(m map[string]float64, name string) (float64, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if matchNameGlob(k, name) {
+			return m[k], true
+		}
+	}
+	return 0, false
+}
+
+// applyScale applies the group's Scale/Offset to matching series and marks them in the name
+// shown in the legend.
+func applyScale(g ChartGroup, series []Metric) []Metric {
+	if len(g.Scale) == 0 && len(g.Offset) == 0 {
+		return series
+	}
+	out := make([]Metric, len(series))
+	for i, m := range series {
+		var notes []string
+		if sc, ok := transformKey(g.Scale, m.Name); ok && sc != 1 {
+			m.Value *= sc
+			notes = append(notes, fmt.Sprintf("x%g", sc))
+		}
+		if off, ok := transformKey(g.Offset, m.Name); ok && off != 0 {
+			m.Value += off
+			notes = append(notes, fmt.Sprintf("%+g", off))
+		}
+		if len(notes) > 0 {
+			m.Name += " (" + strings.Join(notes, ", ") + ")"
+		}
+		out[i] = m
+	}
+	return out
+}
+
+// groupYLabel returns the group's YLabel, falling back to the unit detected from series.
+func groupYLabel(g ChartGroup, series []Metric) string {
+	if g.YLabel != "" {
+		return g.YLabel
+	}
+	return DetectYUnit(series)
+}
+
+// MarkersFromMetrics returns one ChartMarker, labeled with the metric name, per metric whose
+// Name matches any of names (exact or glob).
+func MarkersFromMetrics(metrics []Metric, names []string) []ChartMarker {
+	if len(names) == 0 {
+		return nil
+	}
+	var out []ChartMarker
+	for _, m := range metrics {
+		if m.StartTime.IsZero() {
+			continue
+		}
+		for _, n := range names {
 			n = strings.TrimSpace(n)
-			if n != "" {
-				if strings.ContainsAny(n, "*?[]") {
-					patterns = append(patterns, n)
-				} else {
-					nameSet[n] = struct{}{}
-				}
+			if n == m.Name || (strings.ContainsAny(n, "*?[]") && matchNameGlob(n, m.Name)) {
+				out = append(out, ChartMarker{At: m.StartTime, Label: m.Name})
+				break
 			}
 		}
-		filtered := make([]Metric, 0, len(selected))
-		for _, m := range selected {
-			if _, ok := nameSet[m.Name]; ok {
-				filtered = append(filtered, m)
-				continue
+	}
+	return out
+}
+
+// selectGroupWithAgg returns the series group g charts from its CF-filtered metrics gm:
+// aggregated per the group's agg (if bucketStep > 0), with expressions computed, filtered by
+// Names (exact or glob; fold matches exact names case-insensitively) and limited by TopN.
+func selectGroupWithAgg(g ChartGroup, gm []Metric, bucketStep time.Duration, defaultMode AggregateMode, groupBySource, fold bool) []Metric {
+	exprMode := strings.ToLower(strings.TrimSpace(g.Agg)) == "expr" || strings.ToLower(strings.TrimSpace(g.Agg)) == "expression"
+	// First aggregate (so names carry suffix _Sum/_Avg/...),
+	// then filter by the configured names.
+	selected := gm
+	if bucketStep > 0 {
+		mode := PickAggMode(strings.TrimSpace(g.Agg), defaultMode)
+		agg := NewBucketAggregator(bucketStep, mode)
+		agg.GroupBySource = groupBySource
+		selected = agg.Aggregate(selected)
+	}
+	// For expr mode: if expressions specified, replace selection with computed series
+	if exprMode && len(g.Exprs) > 0 {
+		comp := computeExpressions(selected, g.Exprs)
+		if len(comp) > 0 {
+			selected = comp
+		}
+	} else if len(g.Exprs) > 0 {
+		// Non-expr mode: append computed series in addition to base selection
+		selected = append(selected, computeExpressions(selected, g.Exprs)...)
+	}
+	nameSet := make(map[string]struct{}, len(g.Names))
+	var patterns []string
+	for _, n := range g.Names {
+		n = strings.TrimSpace(n)
+		if n != "" {
+			if strings.ContainsAny(n, "*?[]") {
+				patterns = append(patterns, n)
+			} else {
+				nameSet[foldName(n, fold)] = struct{}{}
 			}
-			matched := false
-			for _, pat := range patterns {
-				if matchNameGlob(pat, m.Name) {
-					matched = true
-					break
-				}
+		}
+	}
+	filtered := make([]Metric, 0, len(selected))
+	for _, m := range selected {
+		name := m.Name
+		if g.StackByCF {
+			var ok bool
+			if name, ok = cfStackName(m); !ok {
+				continue
 			}
-			if matched {
+		}
+		if _, ok := nameSet[foldName(name, fold)]; ok {
+			filtered = append(filtered, m)
+			continue
+		}
+		for _, pat := range patterns {
+			if matchNameGlob(pat, name) {
 				filtered = append(filtered, m)
+				break
 			}
 		}
-		if bucketStep > 0 {
-			// already aggregated above
-		}
-		dlg := NewDialog()
-		if g.Title != "" {
-			dlg.Title = g.Title
-		} else {
-			dlg.Title = fmt.Sprintf("Metrics: %s", strings.Join(g.Names, ", "))
+	}
+	return applyTopN(g, filtered)
+}
+
+// GroupPlan describes what a chart group would render, without rendering it.
+type GroupPlan struct {
+	Out    string
+	Title  string
+	Series []string // distinct series names, sorted
+	Points int
+}
+
+// PlanWithAgg performs the same selection as RenderAllWithAgg for every group and reports
+// the matched series per group. Groups with no series would render an empty chart.
+func (o *ChartOrchestrator) PlanWithAgg(metrics []Metric, bucketStep time.Duration, defaultMode AggregateMode, groupBySource bool) []GroupPlan {
+	out := make([]GroupPlan, 0, len(o.Groups))
+	for _, g := range o.Groups {
+		filtered := selectGroupWithAgg(g, FilterByCF(metrics, g.CF), bucketStep, defaultMode, groupBySource, o.CaseInsensitiveNames)
+		if o.DropZeroSeries {
+			filtered = DropZeroSeries(filtered)
 		}
-		if dir := filepath.Dir(g.Out); dir != "" && dir != "." {
-			_ = ensureDir(dir)
+		names := make(map[string]struct{})
+		for _, m := range filtered {
+			names[m.Name] = struct{}{}
 		}
-		if err := dlg.Render(filtered, g.Out); err != nil {
-			return err
+		plan := GroupPlan{Out: g.Out, Title: g.Title, Points: len(filtered)}
+		for n := range names {
+			plan.Series = append(plan.Series, n)
 		}
+		sort.Strings(plan.Series)
+		out = append(out, plan)
 	}
-	return nil
+	return out
 }
 
 // ParseChartsSpec parses a semicolon-separated spec of groups:
 //   "out1.svg:Title A:Name1,Name2; out2.svg:Title B:Name3,Name4"
 // Title can be omitted: "out.svg:Name1,Name2"
+// A colon inside a segment is written as "\:" ("out.svg:Latency\: p99:DB_Get_P99_us").
+// Names may be globs ("Level*_Size_MB_Sum"), expanded at render time into one series per
+// matching metric, as for -charts-config groups.
 func ParseChartsSpec(spec string) ([]ChartGroup, error) {
 	spec = strings.TrimSpace(spec)
 	if spec == "" {
@@ -191,7 +476,10 @@ func ParseChartsSpec(spec string) ([]ChartGroup, error) {
 		if seg == "" {
 			continue
 		}
-		parts := strings.SplitN(seg, ":", 3)
+		parts := splitUnescaped(seg, ':', 3)
+		for i := range parts {
+			parts[i] = strings.ReplaceAll(parts[i], `\:`, ":")
+		}
 		switch len(parts) {
 		case 3:
 			names := splitCSV(parts[2])
@@ -215,6 +503,42 @@ func ParseChartsSpec(spec string) ([]ChartGroup, error) {
 	return out, nil
 }
 
+// ParseChartsArg parses a -charts style argument: a JSON array of groups (as in a charts
+// config file) when it starts with '[', otherwise the colon spec of ParseChartsSpec. A value
+// starting with '[' that is not valid JSON falls back to the colon spec as well.
+func ParseChartsArg(v string) ([]ChartGroup, error) {
+	v = strings.TrimSpace(v)
+	if !strings.HasPrefix(v, "[") {
+		return ParseChartsSpec(v)
+	}
+	groups, err := parseChartGroups(stripJSONComments([]byte(v)))
+	if err == nil {
+		return groups, nil
+	}
+	if spec, specErr := ParseChartsSpec(v); specErr == nil {
+		return spec, nil
+	}
+	return nil, err
+}
+
+// splitUnescaped is strings.SplitN on sep, skipping separators escaped with a backslash.
+// Escapes are left in place for the caller to remove.
+func splitUnescaped(s string, sep byte, n int) []string {
+	var parts []string
+	last := 0
+	for i := 0; i < len(s) && len(parts) < n-1; i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == sep {
+			parts = append(parts, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(parts, s[last:])
+}
+
 func splitCSV(s string) []string {
 	raw := strings.Split(s, ",")
 	res := make([]string, 0, len(raw))
@@ -233,22 +557,18 @@ func ensureDir(dir string) error {
 	return os.MkdirAll(dir, 0755)
 }
 
-// RenderAllSingle stacks all groups vertically into a single SVG file.
-// It renders each group using Dialog into a temporary SVG, extracts its inner content and dimensions,
+// RenderAllSingle stacks all groups into a single SVG file laid out in Columns columns.
+// It renders each group using Dialog into an in-memory SVG, extracts its inner content and dimensions,
 // then composes a parent SVG with each panel stacked vertically.
 func (o *ChartOrchestrator) RenderAllSingle(metrics []Metric, out string) error {
+	o.Skipped = nil
 	if len(o.Groups) == 0 {
 		return errors.New("no chart groups")
 	}
-	type panel struct {
-		inner  string
-		width  int
-		height int
-	}
-	var panels []panel
+	var panels []svgPanel
 	maxW := 0
-	totalH := 0
 	for _, g := range o.Groups {
+		gm := FilterByCF(metrics, g.CF)
 		nameSet := make(map[string]struct{}, len(g.Names))
 		var patterns []string
 		for _, n := range g.Names {
@@ -257,19 +577,26 @@ func (o *ChartOrchestrator) RenderAllSingle(metrics []Metric, out string) error
 				if strings.ContainsAny(n, "*?[]") {
 					patterns = append(patterns, n)
 				} else {
-					nameSet[n] = struct{}{}
+					nameSet[foldName(n, o.CaseInsensitiveNames)] = struct{}{}
 				}
 			}
 		}
-		selected := make([]Metric, 0, len(metrics))
-		for _, m := range metrics {
-			if _, ok := nameSet[m.Name]; ok {
+		selected := make([]Metric, 0, len(gm))
+		for _, m := range gm {
+			name := m.Name
+			if g.StackByCF {
+				var ok bool
+				if name, ok = cfStackName(m); !ok {
+					continue
+				}
+			}
+			if _, ok := nameSet[foldName(name, o.CaseInsensitiveNames)]; ok {
 				selected = append(selected, m)
 				continue
 			}
 			matched := false
 			for _, pat := range patterns {
-				if matchNameGlob(pat, m.Name) {
+				if matchNameGlob(pat, name) {
 					matched = true
 					break
 				}
@@ -278,32 +605,23 @@ func (o *ChartOrchestrator) RenderAllSingle(metrics []Metric, out string) error
 				selected = append(selected, m)
 			}
 		}
+		selected = applyTopN(g, selected)
 		// skip empty groups to avoid aborting stacked render
 		if len(selected) == 0 {
+			if err := o.emptyGroup(g); err != nil {
+				return err
+			}
 			continue
 		}
-		dlg := NewDialog()
-		if g.Title != "" {
-			dlg.Title = g.Title
-		} else {
-			dlg.Title = fmt.Sprintf("Metrics: %s", strings.Join(g.Names, ", "))
-		}
-		tmp, err := os.CreateTemp("", "chart_*.svg")
-		if err != nil {
-			return err
-		}
-		tmpPath := tmp.Name()
-		_ = tmp.Close()
-		// render to tmp
-		if err := dlg.Render(selected, tmpPath); err != nil {
-			return err
-		}
-		// read and extract
-		data, err := os.ReadFile(tmpPath)
-		_ = os.Remove(tmpPath)
-		if err != nil {
+		missing := o.missingNames(g, selected)
+		selected = applyScale(g, selected)
+		dlg := o.groupDialog(g, gm, selected, missing)
+		// render the panel in memory and extract its inner content
+		var pbuf bytes.Buffer
+		if err := dlg.RenderTo(selected, &pbuf); err != nil {
 			return err
 		}
+		data := pbuf.Bytes()
 		inner, w, h := extractSVGInner(data)
 		if inner == "" || w <= 0 || h <= 0 {
 			// fallback default panel dimensions if not found
@@ -317,11 +635,10 @@ func (o *ChartOrchestrator) RenderAllSingle(metrics []Metric, out string) error
 				h = 600
 			}
 		}
-		panels = append(panels, panel{inner: inner, width: w, height: h})
+		panels = append(panels, svgPanel{inner: inner, width: w, height: h})
 		if w > maxW {
 			maxW = w
 		}
-		totalH += h
 	}
 	if len(panels) == 0 {
 		return errors.New("no panels to render")
@@ -329,143 +646,34 @@ func (o *ChartOrchestrator) RenderAllSingle(metrics []Metric, out string) error
 	if dir := filepath.Dir(out); dir != "" && dir != "." {
 		_ = ensureDir(dir)
 	}
-	var buf bytes.Buffer
-	// Layout panels in two columns
-	cols := 2
-	// compute row heights
-	rowCount := (len(panels) + cols - 1) / cols
-	rowHeights := make([]int, rowCount)
-	totalH = 0
-	for r := 0; r < rowCount; r++ {
-		left := panels[r*cols]
-		rh := left.height
-		if r*cols+1 < len(panels) {
-			right := panels[r*cols+1]
-			if right.height > rh {
-				rh = right.height
-			}
-		}
-		rowHeights[r] = rh
-		totalH += rh
-	}
-	// ensure maxW sane before computing total width
-	if maxW <= 0 {
-		for _, p := range panels {
-			if p.width > maxW {
-				maxW = p.width
-			}
-		}
-		if maxW <= 0 {
-			maxW = 1200
-		}
-	}
-	totalW := maxW * 2 // two-column layout
-	buf.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, totalW, totalH, totalW, totalH))
-	y := 0
-	for r := 0; r < rowCount; r++ {
-		// left
-		idx := r * cols
-		buf.WriteString(fmt.Sprintf(`<g transform="translate(%d,%d)">`, 0, y))
-		buf.WriteString(panels[idx].inner)
-		buf.WriteString(`</g>`)
-		// right if present
-		if idx+1 < len(panels) {
-			buf.WriteString(fmt.Sprintf(`<g transform="translate(%d,%d)">`, maxW, y))
-			buf.WriteString(panels[idx+1].inner)
-			buf.WriteString(`</g>`)
-		}
-		y += rowHeights[r]
-	}
-	buf.WriteString(`</svg>`)
-	return os.WriteFile(out, buf.Bytes(), 0644)
+	return os.WriteFile(out, composePanels(panels, maxW, o.Columns, o.PanelGap), 0644)
 }
 
 // RenderAllSingleWithAgg stacks panels after optional per-group aggregation.
 func (o *ChartOrchestrator) RenderAllSingleWithAgg(metrics []Metric, out string, bucketStep time.Duration, defaultMode AggregateMode, groupBySource bool) error {
+	o.Skipped = nil
 	if len(o.Groups) == 0 {
 		return errors.New("no chart groups")
 	}
-	type panel struct {
-		inner  string
-		width  int
-		height int
-	}
-	var panels []panel
+	var panels []svgPanel
 	maxW := 0
-	totalH := 0
 	for _, g := range o.Groups {
-		// Aggregate first so names have suffixes, then filter.
-		selected := metrics
-		if bucketStep > 0 {
-			mode := PickAggMode(strings.TrimSpace(g.Agg), defaultMode)
-			agg := NewBucketAggregator(bucketStep, mode)
-			agg.GroupBySource = groupBySource
-			selected = agg.Aggregate(selected)
-		}
-		// Expression mode: compute expressions and replace selection
-		exprMode := strings.ToLower(strings.TrimSpace(g.Agg)) == "expr" || strings.ToLower(strings.TrimSpace(g.Agg)) == "expression"
-		if exprMode && len(g.Exprs) > 0 {
-			comp := computeExpressions(selected, g.Exprs)
-			if len(comp) > 0 {
-				selected = comp
-			}
-		} else if len(g.Exprs) > 0 {
-			// Non-expr: append computed series alongside base selection
-			selected = append(selected, computeExpressions(selected, g.Exprs)...)
-		}
-		nameSet := make(map[string]struct{}, len(g.Names))
-		var patterns []string
-		for _, n := range g.Names {
-			n = strings.TrimSpace(n)
-			if n != "" {
-				if strings.ContainsAny(n, "*?[]") {
-					patterns = append(patterns, n)
-				} else {
-					nameSet[n] = struct{}{}
-				}
-			}
-		}
-		filtered := make([]Metric, 0, len(selected))
-		for _, m := range selected {
-			if _, ok := nameSet[m.Name]; ok {
-				filtered = append(filtered, m)
-				continue
-			}
-			matched := false
-			for _, pat := range patterns {
-				if matchNameGlob(pat, m.Name) {
-					matched = true
-					break
-				}
-			}
-			if matched {
-				filtered = append(filtered, m)
-			}
-		}
-		dlg := NewDialog()
-		if g.Title != "" {
-			dlg.Title = g.Title
-		} else {
-			dlg.Title = fmt.Sprintf("Metrics: %s", strings.Join(g.Names, ", "))
-		}
-		// skip empty groups to avoid aborting stacked render
+		gm := FilterByCF(metrics, g.CF)
+		filtered := selectGroupWithAgg(g, gm, bucketStep, defaultMode, groupBySource, o.CaseInsensitiveNames)
 		if len(filtered) == 0 {
+			if err := o.emptyGroup(g); err != nil {
+				return err
+			}
 			continue
 		}
-		tmp, err := os.CreateTemp("", "chart_*.svg")
-		if err != nil {
-			return err
-		}
-		tmpPath := tmp.Name()
-		_ = tmp.Close()
-		if err := dlg.Render(filtered, tmpPath); err != nil {
-			return err
-		}
-		data, err := os.ReadFile(tmpPath)
-		_ = os.Remove(tmpPath)
-		if err != nil {
+		missing := o.missingNames(g, filtered)
+		filtered = applyScale(g, filtered)
+		dlg := o.groupDialog(g, gm, filtered, missing)
+		var pbuf bytes.Buffer
+		if err := dlg.RenderTo(filtered, &pbuf); err != nil {
 			return err
 		}
+		data := pbuf.Bytes()
 		inner, w, h := extractSVGInner(data)
 		if inner == "" || w <= 0 || h <= 0 {
 			if inner == "" {
@@ -478,11 +686,10 @@ func (o *ChartOrchestrator) RenderAllSingleWithAgg(metrics []Metric, out string,
 				h = 600
 			}
 		}
-		panels = append(panels, panel{inner: inner, width: w, height: h})
+		panels = append(panels, svgPanel{inner: inner, width: w, height: h})
 		if w > maxW {
 			maxW = w
 		}
-		totalH += h
 	}
 	if len(panels) == 0 {
 		return errors.New("no panels to render")
@@ -490,23 +697,29 @@ func (o *ChartOrchestrator) RenderAllSingleWithAgg(metrics []Metric, out string,
 	if dir := filepath.Dir(out); dir != "" && dir != "." {
 		_ = ensureDir(dir)
 	}
-	var buf bytes.Buffer
-	// Layout panels in two columns
-	cols := 2
-	rowCount := (len(panels) + cols - 1) / cols
-	rowHeights := make([]int, rowCount)
-	totalH = 0
-	for r := 0; r < rowCount; r++ {
-		left := panels[r*cols]
-		rh := left.height
-		if r*cols+1 < len(panels) {
-			right := panels[r*cols+1]
-			if right.height > rh {
-				rh = right.height
-			}
-		}
-		rowHeights[r] = rh
-		totalH += rh
+	return os.WriteFile(out, composePanels(panels, maxW, o.Columns, o.PanelGap), 0644)
+}
+
+// svgPanel is one rendered group: the inner content of its SVG plus its dimensions.
+type svgPanel struct {
+	inner  string
+	width  int
+	height int
+}
+
+// composePanels lays panels out in a grid of cols columns (default 2) and returns the parent SVG.
+// The effective column count is min(cols, len(panels)), so the total width never includes empty columns.
+// Panels are separated by gap pixels and each panel is clipped to its own box so titles and labels
+// cannot bleed into neighbouring panels.
+func composePanels(panels []svgPanel, maxW int, cols int, gap int) []byte {
+	if gap < 0 {
+		gap = 0
+	}
+	if cols <= 0 {
+		cols = 2
+	}
+	if cols > len(panels) {
+		cols = len(panels)
 	}
 	// ensure maxW sane before computing total width
 	if maxW <= 0 {
@@ -519,23 +732,41 @@ func (o *ChartOrchestrator) RenderAllSingleWithAgg(metrics []Metric, out string,
 			maxW = 1200
 		}
 	}
-	totalW := maxW * 2 // two-column layout
+	// compute row heights
+	rowCount := (len(panels) + cols - 1) / cols
+	rowHeights := make([]int, rowCount)
+	totalH := 0
+	for r := 0; r < rowCount; r++ {
+		rh := 0
+		for c := 0; c < cols && r*cols+c < len(panels); c++ {
+			if h := panels[r*cols+c].height; h > rh {
+				rh = h
+			}
+		}
+		rowHeights[r] = rh
+		totalH += rh
+	}
+	totalH += gap * (rowCount - 1)
+	totalW := maxW*cols + gap*(cols-1)
+	var buf bytes.Buffer
 	buf.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, totalW, totalH, totalW, totalH))
+	buf.WriteString(`<defs>`)
+	for i, p := range panels {
+		buf.WriteString(fmt.Sprintf(`<clipPath id="panel-clip-%d"><rect x="0" y="0" width="%d" height="%d"/></clipPath>`, i, p.width, p.height))
+	}
+	buf.WriteString(`</defs>`)
 	y := 0
 	for r := 0; r < rowCount; r++ {
-		idx := r * cols
-		buf.WriteString(fmt.Sprintf(`<g transform="translate(%d,%d)">`, 0, y))
-		buf.WriteString(panels[idx].inner)
-		buf.WriteString(`</g>`)
-		if idx+1 < len(panels) {
-			buf.WriteString(fmt.Sprintf(`<g transform="translate(%d,%d)">`, maxW, y))
-			buf.WriteString(panels[idx+1].inner)
-			buf.WriteString(`</g>`)
+		for c := 0; c < cols && r*cols+c < len(panels); c++ {
+			idx := r*cols + c
+			buf.WriteString(fmt.Sprintf(`<g transform="translate(%d,%d)"><g clip-path="url(#panel-clip-%d)">`, c*(maxW+gap), y, idx))
+			buf.WriteString(panels[idx].inner)
+			buf.WriteString(`</g></g>`)
 		}
-		y += rowHeights[r]
+		y += rowHeights[r] + gap
 	}
 	buf.WriteString(`</svg>`)
-	return os.WriteFile(out, buf.Bytes(), 0644)
+	return buf.Bytes()
 }
 
 // reWH matches width/height in either single or double quotes, e.g. width="1200" or height='600'
@@ -586,6 +817,58 @@ func ParseChartsConfig(path string) ([]ChartGroup, error) {
 	if err != nil {
 		return nil, err
 	}
+	groups, err := parseChartGroups(stripJSONComments(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, path)
+	}
+	return groups, nil
+}
+
+// stripJSONComments removes // line comments and trailing commas before '}' or ']' outside
+// of strings, so hand-edited configs parse with encoding/json. Valid JSON is returned unchanged.
+func stripJSONComments(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inStr := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inStr {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inStr = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inStr = true
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+			continue
+		case c == '}' || c == ']':
+			// drop a trailing comma (and the whitespace after it) before the closing bracket
+			j := len(out) - 1
+			for j >= 0 && (out[j] == ' ' || out[j] == '\t' || out[j] == '\n' || out[j] == '\r') {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// parseChartGroups decodes a raw group array or a {"groups": [...]} object.
+func parseChartGroups(data []byte) ([]ChartGroup, error) {
 	// try raw array
 	var rawArr []ChartGroup
 	if err := json.Unmarshal(data, &rawArr); err == nil && len(rawArr) > 0 {
@@ -602,7 +885,7 @@ func ParseChartsConfig(path string) ([]ChartGroup, error) {
 	if err := json.Unmarshal(data, &rawArr); err == nil && len(rawArr) == 0 {
 		return rawArr, nil
 	}
-	return nil, fmt.Errorf("unrecognized charts config format: %s", path)
+	return nil, errors.New("unrecognized charts config format")
 }
 
 // ChartsConfigFull supports top-level file type mapping and optional bucket.
@@ -611,25 +894,232 @@ type ChartsConfigFull struct {
 	FileTypes   map[string]string `json:"fileTypes"`
 	// Optional global bucket step like "10m"; CLI may override if not set
 	Bucket      string            `json:"bucket"`
+	// Optional reusable name lists; a group's Names entry "@key" expands to NamedLists[key].
+	NamedLists map[string][]string `json:"namedLists"`
+	// Optional config files merged before this one (paths relative to this file). Later files
+	// override groups with the same Out path, fileTypes keys, namedLists keys and the bucket.
+	Include []string `json:"include"`
+	// Optional StandardDerived keys (e.g. "read_amp") whose series are added to the raw metrics
+	// before charting; included configs contribute their keys too.
+	Derived []string `json:"derived"`
 }
 
 // ParseChartsConfigFull returns groups, file type mapping, and optional bucket (string).
+// // line comments and trailing commas are tolerated.
 func ParseChartsConfigFull(path string) ([]ChartGroup, map[string]string, string, error) {
-	data, err := os.ReadFile(path)
+	full, err := LoadChartsConfig(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return full.Groups, full.FileTypes, full.Bucket, nil
+}
+
+// ParseChartsConfigFromBytes is ParseChartsConfigFull over an in-memory config (e.g. piped or inline JSON).
+// Includes are resolved relative to the working directory.
+func ParseChartsConfigFromBytes(data []byte) ([]ChartGroup, map[string]string, string, error) {
+	full, err := LoadChartsConfigFromBytes(data)
 	if err != nil {
 		return nil, nil, "", err
 	}
+	return full.Groups, full.FileTypes, full.Bucket, nil
+}
+
+// LoadChartsConfig is ParseChartsConfigFull returning the whole merged config, including the
+// keys the tuple form has no room for (e.g. Derived). Includes are merged and "@key" names
+// expanded; Include and NamedLists are left as read.
+func LoadChartsConfig(path string) (ChartsConfigFull, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ChartsConfigFull{}, err
+	}
+	abs, _ := filepath.Abs(path)
+	full, err := parseChartsConfigIn(data, filepath.Dir(abs), []string{abs})
+	if err != nil {
+		return ChartsConfigFull{}, fmt.Errorf("%w: %s", err, path)
+	}
+	return resolveChartsConfig(full)
+}
+
+// LoadChartsConfigFromBytes is LoadChartsConfig over an in-memory config.
+func LoadChartsConfigFromBytes(data []byte) (ChartsConfigFull, error) {
+	full, err := parseChartsConfigIn(data, ".", nil)
+	if err != nil {
+		return ChartsConfigFull{}, err
+	}
+	return resolveChartsConfig(full)
+}
+
+func resolveChartsConfig(full ChartsConfigFull) (ChartsConfigFull, error) {
+	if err := expandNamedLists(full.Groups, full.NamedLists); err != nil {
+		return ChartsConfigFull{}, err
+	}
+	if full.FileTypes == nil {
+		full.FileTypes = map[string]string{}
+	}
+	return full, nil
+}
+
+// parseChartsConfigIn decodes one config and merges its includes beneath it. dir resolves
+// relative include paths; stack holds the absolute paths being parsed, to detect cycles.
+func parseChartsConfigIn(data []byte, dir string, stack []string) (ChartsConfigFull, error) {
+	data = stripJSONComments(data)
 	// Try full object first
 	var full ChartsConfigFull
-	if err := json.Unmarshal(data, &full); err == nil && (len(full.Groups) > 0 || len(full.FileTypes) > 0) {
-		return full.Groups, full.FileTypes, full.Bucket, nil
+	if err := json.Unmarshal(data, &full); err != nil || (len(full.Groups) == 0 && len(full.FileTypes) == 0 && len(full.Include) == 0) {
+		// Fallback to raw array or {groups:[]}
+		groups, err := parseChartGroups(data)
+		if err != nil {
+			return ChartsConfigFull{}, err
+		}
+		return ChartsConfigFull{Groups: groups}, nil
+	}
+	if len(full.Include) == 0 {
+		return full, nil
+	}
+	var merged ChartsConfigFull
+	for _, inc := range full.Include {
+		p := inc
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		p, _ = filepath.Abs(p)
+		for _, s := range stack {
+			if s == p {
+				return ChartsConfigFull{}, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), p)
+			}
+		}
+		incData, err := os.ReadFile(p)
+		if err != nil {
+			return ChartsConfigFull{}, fmt.Errorf("include %s: %w", inc, err)
+		}
+		sub, err := parseChartsConfigIn(incData, filepath.Dir(p), append(append([]string(nil), stack...), p))
+		if err != nil {
+			return ChartsConfigFull{}, fmt.Errorf("include %s: %w", inc, err)
+		}
+		mergeChartsConfig(&merged, sub)
+	}
+	full.Include = nil
+	mergeChartsConfig(&merged, full)
+	return merged, nil
+}
+
+// mergeChartsConfig overlays add onto dst: groups with the same Out replace earlier ones in
+// place, others are appended; map keys and a non-empty bucket override, and derived keys are
+// added once.
+func mergeChartsConfig(dst *ChartsConfigFull, add ChartsConfigFull) {
+	for _, g := range add.Groups {
+		replaced := false
+		if g.Out != "" {
+			for i := range dst.Groups {
+				if dst.Groups[i].Out == g.Out {
+					dst.Groups[i] = g
+					replaced = true
+					break
+				}
+			}
+		}
+		if !replaced {
+			dst.Groups = append(dst.Groups, g)
+		}
+	}
+	for k, v := range add.FileTypes {
+		if dst.FileTypes == nil {
+			dst.FileTypes = make(map[string]string)
+		}
+		dst.FileTypes[k] = v
+	}
+	for k, v := range add.NamedLists {
+		if dst.NamedLists == nil {
+			dst.NamedLists = make(map[string][]string)
+		}
+		dst.NamedLists[k] = v
+	}
+	if add.Bucket != "" {
+		dst.Bucket = add.Bucket
+	}
+	for _, k := range add.Derived {
+		dup := false
+		for _, have := range dst.Derived {
+			if strings.EqualFold(strings.TrimSpace(have), strings.TrimSpace(k)) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			dst.Derived = append(dst.Derived, k)
+		}
+	}
+}
+
+// expandNamedLists replaces "@key" entries in each group's Names with lists[key], in place.
+func expandNamedLists(groups []ChartGroup, lists map[string][]string) error {
+	for i := range groups {
+		var names []string
+		expanded := false
+		for _, n := range groups[i].Names {
+			t := strings.TrimSpace(n)
+			if !strings.HasPrefix(t, "@") {
+				names = append(names, n)
+				continue
+			}
+			list, ok := lists[t[1:]]
+			if !ok {
+				return fmt.Errorf("group %d: unknown named list %q", i, t)
+			}
+			names = append(names, list...)
+			expanded = true
+		}
+		if expanded {
+			groups[i].Names = names
+		}
 	}
-	// Fallback to raw array or {groups:[]}
-	if groups, err := ParseChartsConfig(path); err == nil {
-		return groups, map[string]string{}, "", nil
+	return nil
+}
+
+// ValidateChartsConfig strictly decodes a charts config and reports the first unknown field,
+// naming the group index for fields inside a group (e.g. a misspelled "name" for "names"),
+// and any "derived" key that is not in StandardDerived.
+// The Parse functions stay tolerant; callers opt in by validating first.
+func ValidateChartsConfig(data []byte) error {
+	var groups []json.RawMessage
+	trimmed := bytes.TrimSpace(stripJSONComments(data))
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &groups); err != nil {
+			return err
+		}
 	} else {
-		return nil, nil, "", err
+		var top map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &top); err != nil {
+			return err
+		}
+		if raw, ok := top["groups"]; ok {
+			if err := json.Unmarshal(raw, &groups); err != nil {
+				return fmt.Errorf("groups: %w", err)
+			}
+			delete(top, "groups")
+		}
+		rest, _ := json.Marshal(top)
+		dec := json.NewDecoder(bytes.NewReader(rest))
+		dec.DisallowUnknownFields()
+		var full ChartsConfigFull
+		if err := dec.Decode(&full); err != nil {
+			return fmt.Errorf("charts config: %w", err)
+		}
+		for _, k := range full.Derived {
+			if _, ok := StandardDerived[strings.ToLower(strings.TrimSpace(k))]; !ok {
+				return fmt.Errorf("charts config: unknown derived metric set %q", k)
+			}
+		}
 	}
+	for i, raw := range groups {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		var g ChartGroup
+		if err := dec.Decode(&g); err != nil {
+			return fmt.Errorf("group %d: %w", i, err)
+		}
+	}
+	return nil
 }
 
 func PickAggMode(s string, def AggregateMode) AggregateMode {
@@ -647,6 +1137,10 @@ func PickAggMode(s string, def AggregateMode) AggregateMode {
 		return ModeAvg
 	case "delta", "diff", "incr", "increment", "incremental":
 		return ModeDelta
+	case "distinct", "count_distinct", "countdistinct":
+		return ModeCountDistinct
+	case "sumrate", "sum_rate", "persec", "per_sec", "rate":
+		return ModeSumRate
 	default:
 		return def
 	}
@@ -0,0 +1,70 @@
+package logparser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateChartsConfigDerived(t *testing.T) {
+	ok := `{"derived": ["read_amp", " Write_Amp "], "groups": [{"out": "a.svg", "names": ["Read_Amp*"]}]}`
+	if err := ValidateChartsConfig([]byte(ok)); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+	bad := `{"derived": ["read_amp", "write_ampp"], "groups": []}`
+	err := ValidateChartsConfig([]byte(bad))
+	if err == nil || !strings.Contains(err.Error(), `"write_ampp"`) {
+		t.Fatalf("err = %v, want unknown derived metric set \"write_ampp\"", err)
+	}
+}
+
+func TestLoadChartsConfigMergesDerived(t *testing.T) {
+	dir := t.TempDir()
+	base := `{"derived": ["restarts", "read_amp"], "groups": [{"out": "a.svg", "names": ["X"]}]}`
+	top := `{"include": ["base.json"], "derived": ["READ_AMP", "write_amp"], "groups": [{"out": "b.svg", "names": ["Y"]}]}`
+	if err := os.WriteFile(filepath.Join(dir, "base.json"), []byte(base), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "top.json"), []byte(top), 0644); err != nil {
+		t.Fatal(err)
+	}
+	full, err := LoadChartsConfig(filepath.Join(dir, "top.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"restarts", "read_amp", "write_amp"}
+	if !reflect.DeepEqual(full.Derived, want) {
+		t.Errorf("Derived = %v, want %v", full.Derived, want)
+	}
+	if len(full.Groups) != 2 {
+		t.Errorf("got %d groups, want 2", len(full.Groups))
+	}
+}
+
+func TestChartOrchestratorEmptyGroups(t *testing.T) {
+	dir := t.TempDir()
+	at := time.Date(2025, 11, 30, 3, 0, 0, 0, time.UTC)
+	ms := []Metric{{Name: "A", StartTime: at, Value: 1, SourceType: LogTypeDump}}
+	groups := []ChartGroup{
+		{Out: filepath.Join(dir, "a.svg"), Names: []string{"A"}},
+		{Out: filepath.Join(dir, "none.svg"), Names: []string{"Nope"}},
+	}
+	out := filepath.Join(dir, "all.svg")
+
+	// the zero value skips empty groups, as NewChartOrchestrator does
+	o := &ChartOrchestrator{Groups: groups}
+	if err := o.RenderAllSingle(ms, out); err != nil {
+		t.Fatalf("zero-value orchestrator: %v", err)
+	}
+	if want := []string{groups[1].Out}; !reflect.DeepEqual(o.Skipped, want) {
+		t.Errorf("Skipped = %v, want %v", o.Skipped, want)
+	}
+
+	o.FailOnEmpty = true
+	if err := o.RenderAllSingle(ms, out); err == nil || !strings.Contains(err.Error(), "none.svg") {
+		t.Errorf("FailOnEmpty: err = %v, want one naming none.svg", err)
+	}
+}
@@ -3,6 +3,7 @@ package logparser
 import (
 	"bufio"
 	"errors"
+	"io"
 	"os"
 	"regexp"
 	"strings"
@@ -21,10 +22,25 @@ const (
 	LogTypeEvents LogType = "EVENTS"
 	// Pika Slowlog 指标来源
 	LogTypeSlowLog LogType = "SLOWLOG"
+	// Pika "Log file created at:" 文件边界（仅在 EmitRotation 时产生）
+	LogTypeRotation LogType = "ROTATION"
 	// 其他未归类
 	LogTypeOther LogType = "OTHER"
 )
 
+// DefaultTimeLayout is the RocksDB LOG head timestamp layout, also used by default when
+// formatting times in CSV and JSON output.
+const DefaultTimeLayout = "2006/01/02-15:04:05.000000"
+
+// ErrEOF is returned by Seek when no item starts at or after the requested time.
+// It is io.EOF, so existing err == io.EOF and err.Error() == "EOF" checks keep working;
+// prefer errors.Is(err, ErrEOF).
+var ErrEOF = io.EOF
+
+// ErrNoItem is returned by Value when there is no current item (before a successful Seek,
+// or after Next has returned false). Use HasItem to check without taking the error path.
+var ErrNoItem = errors.New("no current item")
+
 // LogItem represents one logical log item: starts at a timestamped line, includes
 // all following non-timestamp lines; for DUMPING STATS, it also includes the immediate
 // next timestamped DB Stats header ([/db_impl.cc:670]) and its continuation.
@@ -38,11 +54,31 @@ type LogItem struct {
 type RocksDLogParser struct {
 	path    string
 	file    *os.File
+	stream  io.ReadCloser // set instead of file for NewRocksDLogParserFromReader
 	sc      *bufio.Scanner
-	reTs    *regexp.Regexp // timestamp-only: YYYY/MM/DD-HH:MM:SS.micros
+	reTs    *regexp.Regexp // timestamp-only: YYYY/MM/DD-HH:MM:SS.micros or RFC3339
 	reHdr   *regexp.Regexp // strict header (thread, [LEVEL], [/file:line])
 	cur     *LogItem
-	peekBuf *string
+	peekBuf []string // pushed-back lines, read last-in first-out
+
+	// TailWindow is the initial number of bytes read from the end of the file by the Seek
+	// fast path; it grows until a complete head line is found. 0 uses 1MB.
+	TailWindow int64
+	// SkipTypes lists item types that Seek and Next step over transparently; such items are
+	// never surfaced via Value (e.g. LogTypeOther when only extracting metrics).
+	SkipTypes []LogType
+	// MaxItemLines caps the continuation lines gathered into one item, bounding memory when a
+	// malformed LOG lacks heads; lines past the cap are dropped up to the next head and the
+	// item is counted in Truncated. 0 means unlimited.
+	MaxItemLines int
+	// Truncated counts the items cut at MaxItemLines so far.
+	Truncated int
+	// OnProgress, when set, is called every ProgressEvery lines read (0 uses 100000) during
+	// both Seek and Next, with the bytes consumed so far and the items built so far, so a
+	// long scan can report progress.
+	OnProgress    func(bytesRead int64, itemsSeen int)
+	ProgressEvery int
+	progress      progressCounter
 }
 
 // NewRocksDLogParser creates a new RocksDLogParser. Use Close when done.
@@ -51,19 +87,44 @@ func NewRocksDLogParser(path string) (*RocksDLogParser, error) {
 	if err != nil {
 		return nil, err
 	}
-	p := &RocksDLogParser{
-		path: path,
-		file: f,
-		sc:   bufio.NewScanner(f),
+	p := newRocksDLogParser(f)
+	p.path = path
+	p.file = f
+	return p, nil
+}
+
+// NewRocksDLogParserFromReader creates a forward-only RocksDLogParser over r, e.g. an HTTP
+// response body. Seek scans linearly (no tail or bisect fast path) and Rewind is not
+// supported. Close closes r when it is an io.Closer.
+func NewRocksDLogParserFromReader(r io.Reader) *RocksDLogParser {
+	p := newRocksDLogParser(r)
+	if rc, ok := r.(io.ReadCloser); ok {
+		p.stream = rc
+	} else {
+		p.stream = io.NopCloser(r)
+	}
+	return p
+}
+
+func newRocksDLogParser(r io.Reader) *RocksDLogParser {
+	return &RocksDLogParser{
+		sc: bufio.NewScanner(r),
 		// timestamp-only head
-		reTs:  regexp.MustCompile(`^[0-9]{4}/[0-9]{2}/[0-9]{2}-[0-9]{2}:[0-9]{2}:[0-9]{2}\.[0-9]+`),
+		reTs:  regexp.MustCompile(`^(?:[0-9]{4}/[0-9]{2}/[0-9]{2}-[0-9]{2}:[0-9]{2}:[0-9]{2}\.[0-9]+|[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(?:\.[0-9]+)?(?:Z|[+-][0-9]{2}:[0-9]{2}))`),
 		reHdr: regexp.MustCompile(`^[0-9]{4}/[0-9]{2}/[0-9]{2}-[0-9]{2}:[0-9]{2}:[0-9]{2}\.[0-9]+\s+[0-9A-Fa-f]+\s+\[[A-Z]+\]\s+\[/[^]]+:[0-9]+\]`),
 	}
-	return p, nil
 }
 
-// Close releases file handle.
+// closed reports whether the parser has no open input.
+func (p *RocksDLogParser) closed() bool { return p.file == nil && p.stream == nil }
+
+// Close releases file handle (or the reader's stream).
 func (p *RocksDLogParser) Close() error {
+	if p.stream != nil {
+		err := p.stream.Close()
+		p.stream = nil
+		return err
+	}
 	if p.file != nil {
 		err := p.file.Close()
 		p.file = nil
@@ -72,15 +133,59 @@ func (p *RocksDLogParser) Close() error {
 	return nil
 }
 
+// Rewind moves the parser back to the beginning of the file so it can be scanned again.
+// It returns an error if the parser is closed.
+func (p *RocksDLogParser) Rewind() error {
+	if p.stream != nil {
+		return errors.New("cannot rewind a stream")
+	}
+	if p.file == nil {
+		return errors.New("parser closed")
+	}
+	if err := p.reposition(0); err != nil {
+		return err
+	}
+	p.progress = progressCounter{}
+	return nil
+}
+
+// reposition restarts reading the file at byte off with no current item. A line cut by a
+// non-zero off is dropped so reading resumes at a line boundary.
+func (p *RocksDLogParser) reposition(off int64) error {
+	if _, err := p.file.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	p.sc = bufio.NewScanner(p.file)
+	p.cur = nil
+	p.peekBuf = nil
+	p.progress.bytes = off
+	if off > 0 && p.sc.Scan() {
+		p.progress.bytes += int64(len(p.sc.Bytes())) + 1
+	}
+	return nil
+}
+
 // Seek positions to the first log item whose start timestamp >= at.
 // After Seek, the matched item is available via Value(). On EOF returns error.
+// A zero at positions to the first item in the file. On a file the search always starts
+// over, so a later Seek may move backward; a stream resumes from its current position.
 func (p *RocksDLogParser) Seek(at time.Time) error {
-	if p.file == nil {
+	if p.closed() {
 		return errors.New("parser closed")
 	}
-	// Fast path: if the file's last head timestamp is not after 'at', return EOF quickly.
-	if ok, _ := p.fastHasAnyAfter(at); !ok {
-		return ioEOF()
+	// Fast path: if the file's last head timestamp is before 'at', return EOF quickly.
+	// A zero 'at' means "from the beginning", so there is nothing to short-circuit. Streams
+	// cannot seek and are scanned linearly.
+	if p.file != nil {
+		if !at.IsZero() {
+			if ok, _ := p.fastHasAnyAfter(at); !ok {
+				return ioEOF()
+			}
+		}
+		// Large files: jump close to the target by binary search over byte offsets.
+		if err := p.reposition(p.bisect(at)); err != nil {
+			return err
+		}
 	}
 	// scan until we find a head with ts >= at
 	for {
@@ -94,7 +199,9 @@ func (p *RocksDLogParser) Seek(at time.Time) error {
 		}
 		if ht, ok := headTime(line); ok && (ht.Equal(at) || ht.After(at)) {
 			// build item starting at current head
-			_ = p.buildItemFromHead(line)
+			if it := p.buildItemFromHead(line); p.skipType(it.Type) && !p.Next() {
+				return ioEOF()
+			}
 			return nil
 		}
 		// otherwise skip this item quickly: consume continuation lines until next timestamp
@@ -114,7 +221,7 @@ func (p *RocksDLogParser) Seek(at time.Time) error {
 // Next advances to the next log item.
 // It returns true if a next item is available; false on EOF or closed parser.
 func (p *RocksDLogParser) Next() bool {
-	if p.file == nil {
+	if p.closed() {
 		return false
 	}
 	// find next head
@@ -125,26 +232,67 @@ func (p *RocksDLogParser) Next() bool {
 			return false
 		}
 		if p.reTs.MatchString(stripLOGPrefix(line)) {
-			_ = p.buildItemFromHead(line)
+			if it := p.buildItemFromHead(line); p.skipType(it.Type) {
+				continue
+			}
+			return true
+		}
+	}
+}
+
+// skipType reports whether items of type t are configured to be skipped.
+func (p *RocksDLogParser) skipType(t LogType) bool {
+	for _, st := range p.SkipTypes {
+		if st == t {
 			return true
 		}
 	}
+	return false
 }
 
+// HasItem reports whether Value has a current item to return.
+func (p *RocksDLogParser) HasItem() bool { return p.cur != nil }
+
 // Value returns the last built item (after Seek). Returns error if none.
 func (p *RocksDLogParser) Value() (LogItem, error) {
 	if p.cur == nil {
-		return LogItem{}, errors.New("no current item")
+		return LogItem{}, ErrNoItem
 	}
 	return *p.cur, nil
 }
 
 func (p *RocksDLogParser) buildItemFromHead(head string) LogItem {
+	p.progress.items++
 	item := LogItem{
 		StartTime: func() time.Time { t, _ := headTime(head); return t }(),
 		Lines:     []string{head},
 		Type:      classifyHead(head),
 	}
+	truncated := false
+	// keep appends a continuation line unless the item is already at MaxItemLines.
+	keep := func(line string) {
+		if p.MaxItemLines > 0 && len(item.Lines) > p.MaxItemLines {
+			truncated = true
+			return
+		}
+		item.Lines = append(item.Lines, line)
+	}
+	// keepStats appends a DB Stats head and its continuation lines, stopping before the
+	// next timestamp head.
+	keepStats := func(statsHead string) {
+		keep(statsHead)
+		for {
+			l2, ok2 := p.nextLine()
+			if !ok2 {
+				break
+			}
+			if p.reTs.MatchString(stripLOGPrefix(l2)) {
+				p.unread(l2)
+				break
+			}
+			keep(l2)
+		}
+	}
 	// Gather continuation lines until next timestamp (timestamp-only)
 	for {
 		line, ok := p.nextLine()
@@ -156,17 +304,16 @@ func (p *RocksDLogParser) buildItemFromHead(head string) LogItem {
 			// is a DB Stats header ([/db_impl.cc:670]), include that head and its continuations,
 			// then stop at the subsequent timestamp head.
 			if item.Type == LogTypeDump && isDBStatsHead(line) {
-				item.Lines = append(item.Lines, line)
-				for {
-					l2, ok2 := p.nextLine()
-					if !ok2 {
-						break
-					}
-					if p.reTs.MatchString(stripLOGPrefix(l2)) {
-						p.unread(l2)
-						break
-					}
-					item.Lines = append(item.Lines, l2)
+				keepStats(line)
+				break
+			}
+			// Under concurrent logging another thread's head may land between DUMPING STATS
+			// and its DB Stats header: look past a few such items for the header, and hand
+			// them back to be read as their own items after this one.
+			if item.Type == LogTypeDump && strings.Contains(head, "DUMPING STATS") {
+				if stats, skipped, ok := p.interleavedDBStats(line); ok {
+					keepStats(stats)
+					p.pushBack(skipped)
 				}
 				break
 			}
@@ -174,17 +321,18 @@ func (p *RocksDLogParser) buildItemFromHead(head string) LogItem {
 			p.unread(line)
 			break
 		}
-		item.Lines = append(item.Lines, line)
+		keep(line)
 	}
-	// If not dump/stat, re-classify by content heuristics
-	if item.Type == LogTypeOther {
-		item.Type = classifyByContent(item.Lines)
+	if truncated {
+		p.Truncated++
 	}
+	// If not dump/stat, re-classify by content heuristics
+	item.Type = ClassifyItem(item.Lines)
 	p.cur = &item
 	return item
 }
 
-// fastHasAnyAfter checks the tail of the RocksDB LOG file to see if any head timestamp > at exists.
+// fastHasAnyAfter checks the tail of the RocksDB LOG file to see if any head timestamp >= at exists.
 func (p *RocksDLogParser) fastHasAnyAfter(at time.Time) (bool, error) {
 	if p.file == nil {
 		return false, errors.New("parser closed")
@@ -197,50 +345,204 @@ func (p *RocksDLogParser) fastHasAnyAfter(at time.Time) (bool, error) {
 	if size <= 0 {
 		return false, nil
 	}
-	const tailReadBytes int64 = 1024 * 1024
-	start := size - tailReadBytes
-	if start < 0 {
-		start = 0
-	}
-	buf := make([]byte, int(size-start))
-	_, err = p.file.ReadAt(buf, start)
-	if err != nil && !strings.Contains(strings.ToLower(err.Error()), "eof") {
-		// Ignore read-at EOF; otherwise, bail to normal path
+	lastTs, ok := tailLastHeadTime(p.file, size, p.TailWindow, headTime)
+	if !ok {
+		// Not found: fall back to normal Seek scanning.
 		return true, nil
 	}
-	lastTs := time.Time{}
-	lines := strings.Split(string(buf), "\n")
-	for _, ln := range lines {
-		if t, ok := headTime(ln); ok {
-			if t.After(lastTs) {
-				lastTs = t
+	return !lastTs.Before(at), nil
+}
+
+// seekLinearWindow is the byte range below which Seek stops bisecting and scans linearly.
+const seekLinearWindow int64 = 1024 * 1024
+
+// bisect returns the offset Seek scans from for at: for files larger than a couple of linear
+// windows, a point shortly before the first head >= at, found by binary search; otherwise 0.
+// Head timestamps are assumed to be (mostly) increasing; the remaining window is scanned
+// linearly by Seek, which absorbs small disorder from concurrent writers. A zero at or any
+// read error also yields 0, i.e. a full linear scan.
+func (p *RocksDLogParser) bisect(at time.Time) int64 {
+	stat, err := p.file.Stat()
+	if err != nil || at.IsZero() || stat.Size() <= 2*seekLinearWindow {
+		return 0
+	}
+	lo, hi := int64(0), stat.Size()
+	for hi-lo > seekLinearWindow {
+		mid := lo + (hi-lo)/2
+		t, ok, err := p.firstHeadAfter(mid, hi)
+		if err != nil {
+			return 0
+		}
+		if ok && t.Before(at) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// firstHeadAfter returns the time of the first complete head line starting in (off, limit).
+func (p *RocksDLogParser) firstHeadAfter(off, limit int64) (time.Time, bool, error) {
+	r := bufio.NewReader(io.NewSectionReader(p.file, off, limit-off))
+	// the line containing off is partial and never a candidate
+	if _, err := r.ReadString('\n'); err != nil {
+		if err == io.EOF {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+	for {
+		ln, err := r.ReadString('\n')
+		if len(ln) > 0 {
+			ln = strings.TrimRight(ln, "\r\n")
+			if p.reTs.MatchString(stripLOGPrefix(ln)) {
+				if t, ok := headTime(ln); ok {
+					return t, true, nil
+				}
 			}
 		}
+		if err == io.EOF {
+			return time.Time{}, false, nil
+		}
+		if err != nil {
+			return time.Time{}, false, err
+		}
 	}
-	if lastTs.IsZero() {
-		// Not found: fall back to normal Seek scanning.
-		return true, nil
+}
+
+// defaultTailWindow is the initial tail read size used by the Seek fast path.
+const defaultTailWindow int64 = 1024 * 1024
+
+// tailLastHeadTime reads the file backward in growing chunks (starting at window bytes, doubling)
+// until at least one complete line parses as a head, and returns the latest head time found.
+// The first line of a chunk that does not start at offset 0 is partial and is never parsed,
+// so a timestamp cut at the read boundary cannot be mistaken for a head.
+func tailLastHeadTime(f *os.File, size, window int64, parse func(string) (time.Time, bool)) (time.Time, bool) {
+	if window <= 0 {
+		window = defaultTailWindow
+	}
+	for {
+		start := size - window
+		if start < 0 {
+			start = 0
+		}
+		buf := make([]byte, int(size-start))
+		n, err := f.ReadAt(buf, start)
+		if err != nil && err != io.EOF {
+			return time.Time{}, false
+		}
+		lines := strings.Split(string(buf[:n]), "\n")
+		if start > 0 && len(lines) > 0 {
+			lines = lines[1:]
+		}
+		lastTs := time.Time{}
+		for _, ln := range lines {
+			if t, ok := parse(ln); ok && t.After(lastTs) {
+				lastTs = t
+			}
+		}
+		if !lastTs.IsZero() {
+			return lastTs, true
+		}
+		if start == 0 {
+			return time.Time{}, false
+		}
+		window *= 2
 	}
-	return lastTs.After(at), nil
 }
 
 func (p *RocksDLogParser) nextLine() (string, bool) {
-	if p.peekBuf != nil {
-		s := *p.peekBuf
-		p.peekBuf = nil
+	if n := len(p.peekBuf); n > 0 {
+		s := p.peekBuf[n-1]
+		p.peekBuf = p.peekBuf[:n-1]
 		return s, true
 	}
 	if p.sc.Scan() {
+		p.progress.line(len(p.sc.Bytes()), p.ProgressEvery, p.OnProgress)
 		return p.sc.Text(), true
 	}
 	return "", false
 }
 
+// progressCounter tracks the lines, bytes and items a parser has consumed for OnProgress.
+type progressCounter struct {
+	bytes int64
+	lines int
+	items int
+}
+
+// line records one scanned line of n bytes (plus its newline) and calls fn every every lines.
+func (c *progressCounter) line(n, every int, fn func(int64, int)) {
+	c.bytes += int64(n) + 1
+	c.lines++
+	if fn == nil {
+		return
+	}
+	if every <= 0 {
+		every = 100000
+	}
+	if c.lines%every == 0 {
+		fn(c.bytes, c.items)
+	}
+}
+
 func (p *RocksDLogParser) unread(s string) {
-	if p.peekBuf != nil {
-		panic("unread buffer already occupied")
+	p.peekBuf = append(p.peekBuf, s)
+}
+
+// pushBack unreads lines so that nextLine returns them in their original order.
+func (p *RocksDLogParser) pushBack(lines []string) {
+	for i := len(lines) - 1; i >= 0; i-- {
+		p.unread(lines[i])
 	}
-	p.peekBuf = &s
+}
+
+const (
+	// maxInterleavedHeads is how many foreign items interleavedDBStats looks past.
+	maxInterleavedHeads = 2
+	// maxInterleavedLines bounds the lines buffered while looking for the DB Stats header.
+	maxInterleavedLines = 256
+)
+
+// interleavedDBStats reads ahead from first, a head that is not a DB Stats header, looking for
+// one within the next maxInterleavedHeads items. On success it returns that header and the
+// lines read before it (first included); otherwise everything read is pushed back.
+func (p *RocksDLogParser) interleavedDBStats(first string) (string, []string, bool) {
+	lines := []string{first}
+	heads := 1
+	for len(lines) < maxInterleavedLines {
+		l, ok := p.nextLine()
+		if !ok {
+			break
+		}
+		if p.reTs.MatchString(stripLOGPrefix(l)) {
+			if isDBStatsHead(l) {
+				return l, lines, true
+			}
+			if heads == maxInterleavedHeads {
+				lines = append(lines, l)
+				break
+			}
+			heads++
+		}
+		lines = append(lines, l)
+	}
+	p.pushBack(lines)
+	return "", nil, false
+}
+
+// ClassifyItem returns the LogType of a RocksDB LOG item given its lines (head first): the head
+// heuristics (STATISTICS, DUMPING STATS, DB Stats) win, then the content heuristics (events,
+// stall notices). Useful to re-classify items stored or transformed outside the parser.
+func ClassifyItem(lines []string) LogType {
+	if len(lines) == 0 {
+		return LogTypeOther
+	}
+	if t := classifyHead(lines[0]); t != LogTypeOther {
+		return t
+	}
+	return classifyByContent(lines)
 }
 
 func classifyHead(line string) LogType {
@@ -289,6 +591,39 @@ func classifyByContent(lines []string) LogType {
 	return LogTypeOther
 }
 
+// IsDanglingDump reports whether item is a DUMPING STATS item whose DB Stats half is missing,
+// which happens when the LOG rotated right after the DUMPING STATS head.
+func IsDanglingDump(item LogItem) bool {
+	if item.Type != LogTypeDump || len(item.Lines) == 0 || isDBStatsHead(item.Lines[0]) {
+		return false
+	}
+	for _, l := range item.Lines[1:] {
+		if isDBStatsHead(l) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsOrphanDBStats reports whether item is a DB Stats half ([/db_impl.cc:670]) without its
+// DUMPING STATS head, e.g. the first item of a LOG that rotated mid-dump.
+func IsOrphanDBStats(item LogItem) bool {
+	return item.Type == LogTypeDump && len(item.Lines) > 0 && isDBStatsHead(item.Lines[0])
+}
+
+// JoinSplitDump merges a dangling DUMP item (end of one file) with an orphan DB Stats item
+// (start of the next file) into one DUMP item stamped with the DUMPING STATS head time.
+// It returns false, leaving both untouched, when the pair does not match.
+func JoinSplitDump(prev, next LogItem) (LogItem, bool) {
+	if !IsDanglingDump(prev) || !IsOrphanDBStats(next) {
+		return LogItem{}, false
+	}
+	lines := make([]string, 0, len(prev.Lines)+len(next.Lines))
+	lines = append(lines, prev.Lines...)
+	lines = append(lines, next.Lines...)
+	return LogItem{StartTime: prev.StartTime, Lines: lines, Type: LogTypeDump}, true
+}
+
 func isDBStatsHead(line string) bool {
 	s := stripLOGPrefix(line)
 	// Strict head containing [/db_impl.cc:670]
@@ -299,6 +634,8 @@ func stripLOGPrefix(s string) string {
 	return strings.TrimLeft(strings.TrimPrefix(s, "LOG:"), " ")
 }
 
+// headTime parses the timestamp token of a head line: DefaultTimeLayout, its seconds-only
+// form, or RFC3339 (with optional fraction), as written by loggers reconfigured for ISO times.
 func headTime(s string) (time.Time, bool) {
 	s2 := stripLOGPrefix(s)
 	// take token up to first space
@@ -310,12 +647,15 @@ func headTime(s string) (time.Time, bool) {
 		}
 	}
 	// try micros then seconds
-	if t, err := time.ParseInLocation("2006/01/02-15:04:05.000000", ts, time.Local); err == nil {
+	if t, err := time.ParseInLocation(DefaultTimeLayout, ts, time.Local); err == nil {
 		return t, true
 	}
 	if t, err := time.ParseInLocation("2006/01/02-15:04:05", ts, time.Local); err == nil {
 		return t, true
 	}
+	if t, err := time.ParseInLocation(time.RFC3339Nano, ts, time.Local); err == nil {
+		return t, true
+	}
 	return time.Time{}, false
 }
 
@@ -344,7 +684,7 @@ func normalizeKey(s string) (string, error) {
 	return "", errors.New("unsupported time format")
 }
 
-func ioEOF() error { return errors.New("EOF") }
+func ioEOF() error { return ErrEOF }
 
 // PikaSlowLogItemParser groups Pika ERROR slowlog lines into LogItems.
 // Each LogItem corresponds to one request (same command + start_time(s)),
@@ -361,6 +701,29 @@ type PikaSlowLogItemParser struct {
 	curYear     string
 	cur         *LogItem
 	peekBuf     *string
+
+	// TailWindow is the initial number of bytes read from the end of the file by the Seek
+	// fast path; it grows until a complete head line is found. 0 uses 1MB.
+	TailWindow int64
+	// HeadPatterns are extra command-head matchers tried after the built-in ones, for builds
+	// that log slow commands in another shape (e.g. from pika_client_conn.cc). Lines are
+	// matched with the "LOG:" prefix stripped; capture group 1, if any, is the command name.
+	HeadPatterns []*regexp.Regexp
+	// MaxItemLines and Truncated are as in RocksDLogParser: lines an item would gather past
+	// the cap are dropped up to the next command head.
+	MaxItemLines int
+	Truncated    int
+	// OnProgress and ProgressEvery are as in RocksDLogParser.
+	OnProgress    func(bytesRead int64, itemsSeen int)
+	ProgressEvery int
+	progress      progressCounter
+	// OnRotate, when set, is called for every "Log file created at:" header the parser passes,
+	// during both Seek and Next, with the header time and the year now used for glog heads.
+	// Concatenated rotated logs carry one such header per file section.
+	OnRotate func(created time.Time, line string)
+	// EmitRotation makes Next also return each header as a synthetic LogTypeRotation item
+	// (one line, StartTime = created time), so a "log rotated here" marker can be shown.
+	EmitRotation bool
 }
 
 func NewPikaSlowLogItemParser(path string) (*PikaSlowLogItemParser, error) {
@@ -389,19 +752,39 @@ func (p *PikaSlowLogItemParser) Close() error {
 	return nil
 }
 
+// Rewind moves the parser back to the beginning of the file so it can be scanned again.
+// It returns an error if the parser is closed.
+func (p *PikaSlowLogItemParser) Rewind() error {
+	if p.file == nil {
+		return errors.New("parser closed")
+	}
+	if _, err := p.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	p.sc = bufio.NewScanner(p.file)
+	p.curYear = ""
+	p.cur = nil
+	p.peekBuf = nil
+	p.progress = progressCounter{}
+	return nil
+}
+
 // Seek positions to the first slowlog item whose head timestamp >= at.
 func (p *PikaSlowLogItemParser) Seek(at time.Time) error {
 	if p.file == nil {
 		return errors.New("parser closed")
 	}
-	// Fast path: if the file's last head timestamp is not after 'at', return EOF quickly.
-	if ok, _ := p.fastHasAnyAfter(at); !ok {
-		return errors.New("EOF")
+	// Fast path: if the file's last head timestamp is before 'at', return EOF quickly.
+	// A zero 'at' means "from the beginning", so there is nothing to short-circuit.
+	if !at.IsZero() {
+		if ok, _ := p.fastHasAnyAfter(at); !ok {
+			return ErrEOF
+		}
 	}
 	for {
 		line, ok := p.nextLine()
 		if !ok {
-			return errors.New("EOF")
+			return ErrEOF
 		}
 		ts, isHead := p.parseGlogTs(line)
 		if !isHead {
@@ -414,7 +797,7 @@ func (p *PikaSlowLogItemParser) Seek(at time.Time) error {
 			for {
 				l2, ok2 := p.nextLine()
 				if !ok2 {
-					return errors.New("EOF")
+					return ErrEOF
 				}
 				if t2, isHead2 := p.parseGlogTs(l2); isHead2 {
 					// If still before target, keep skipping; else evaluate this head
@@ -439,7 +822,7 @@ func (p *PikaSlowLogItemParser) Seek(at time.Time) error {
 	}
 }
 
-// fastHasAnyAfter checks the tail of the file to see if there exists any head timestamp > at.
+// fastHasAnyAfter checks the tail of the file to see if there exists any head timestamp >= at.
 // It avoids full-file scanning when the target time is beyond the file's last entry.
 func (p *PikaSlowLogItemParser) fastHasAnyAfter(at time.Time) (bool, error) {
 	if p.file == nil {
@@ -463,34 +846,16 @@ func (p *PikaSlowLogItemParser) fastHasAnyAfter(at time.Time) (bool, error) {
 	if year == "" {
 		year = "2025"
 	}
-	// Read last chunk of the file (up to 1MB) and find the last head timestamp.
-	const tailReadBytes int64 = 1024 * 1024
-	start := size - tailReadBytes
-	if start < 0 {
-		start = 0
-	}
-	buf := make([]byte, int(size-start))
-	_, err = p.file.ReadAt(buf, start)
-	if err != nil && !strings.Contains(strings.ToLower(err.Error()), "eof") {
-		// Ignore read-at EOF; otherwise, bail to normal path
-		return true, nil
-	}
-	lastTs := time.Time{}
-	lines := strings.Split(string(buf), "\n")
-	for _, ln := range lines {
+	// Read the tail backward until a complete head line is found.
+	lastTs, ok := tailLastHeadTime(p.file, size, p.TailWindow, func(ln string) (time.Time, bool) {
 		// parseGlogTs requires full line; reuse logic with temporary year
-		t, ok := p.parseGlogTsWithYear(ln, year)
-		if ok {
-			if t.After(lastTs) {
-				lastTs = t
-			}
-		}
-	}
-	if lastTs.IsZero() {
+		return p.parseGlogTsWithYear(ln, year)
+	})
+	if !ok {
 		// Not found: fall back to normal Seek to be safe.
 		return true, nil
 	}
-	return lastTs.After(at), nil
+	return !lastTs.Before(at), nil
 }
 
 // scanYearFromHead reads a small prefix of the file and attempts to capture the year from
@@ -555,7 +920,10 @@ func (p *PikaSlowLogItemParser) Next() bool {
 		}
 		_, isHead := p.parseGlogTs(line)
 		if !isHead {
-			p.tryUpdateCreated(line)
+			if created, ok := p.tryUpdateCreated(line); ok && p.EmitRotation {
+				p.cur = &LogItem{StartTime: created, Lines: []string{line}, Type: LogTypeRotation}
+				return true
+			}
 			continue
 		}
 		if p.isCommandHead(line) {
@@ -565,15 +933,19 @@ func (p *PikaSlowLogItemParser) Next() bool {
 	}
 }
 
+// HasItem reports whether Value has a current item to return.
+func (p *PikaSlowLogItemParser) HasItem() bool { return p.cur != nil }
+
 // Value returns the current LogItem.
 func (p *PikaSlowLogItemParser) Value() (LogItem, error) {
 	if p.cur == nil {
-		return LogItem{}, errors.New("no current item")
+		return LogItem{}, ErrNoItem
 	}
 	return *p.cur, nil
 }
 
 func (p *PikaSlowLogItemParser) buildItemFromHead(head string) LogItem {
+	p.progress.items++
 	ts, _ := p.parseGlogTs(head)
 	item := LogItem{
 		StartTime: ts,
@@ -581,27 +953,31 @@ func (p *PikaSlowLogItemParser) buildItemFromHead(head string) LogItem {
 		Type:      LogTypeSlowLog,
 	}
 	cmd, startSec := p.extractCommandAndStart(head)
+	truncated := false
 	// collect continuation lines until next command head
 	for {
 		line, ok := p.nextLine()
 		if !ok {
 			break
 		}
-		if p.isCommandHead(line) {
+		// a new file section ends the item; leave the header for Next to apply its year
+		if p.isCommandHead(line) || p.isCreatedLine(line) {
 			p.unread(line)
 			break
 		}
-		// include NET_DEBUG line for same command
-		if p.isNetDebugForCmd(line, cmd) {
-			item.Lines = append(item.Lines, line)
+		// include NET_DEBUG line for same command, and lines that mention the same
+		// start_time(s) (rare); otherwise ignore unrelated noise
+		if !p.isNetDebugForCmd(line, cmd) && (startSec == "" || !p.hasStartSec(line, startSec)) {
 			continue
 		}
-		// include lines that mention the same start_time(s) (rare)
-		if startSec != "" && p.hasStartSec(line, startSec) {
-			item.Lines = append(item.Lines, line)
+		if p.MaxItemLines > 0 && len(item.Lines) > p.MaxItemLines {
+			truncated = true
 			continue
 		}
-		// otherwise ignore unrelated noise
+		item.Lines = append(item.Lines, line)
+	}
+	if truncated {
+		p.Truncated++
 	}
 	p.cur = &item
 	return item
@@ -634,11 +1010,24 @@ func (p *PikaSlowLogItemParser) parseGlogTs(line string) (time.Time, bool) {
 	return time.Time{}, false
 }
 
-func (p *PikaSlowLogItemParser) tryUpdateCreated(line string) {
+// tryUpdateCreated applies a "Log file created at:" header: it resets curYear, fires OnRotate
+// and returns the header time. ok is false for any other line.
+func (p *PikaSlowLogItemParser) tryUpdateCreated(line string) (created time.Time, ok bool) {
 	s := strings.TrimLeft(strings.TrimPrefix(line, "LOG:"), " ")
-	if c := p.reCreated.FindStringSubmatch(s); len(c) == 5 {
-		p.curYear = c[1]
+	c := p.reCreated.FindStringSubmatch(s)
+	if len(c) != 5 {
+		return time.Time{}, false
+	}
+	p.curYear = c[1]
+	created, _ = time.ParseInLocation("2006/01/02 15:04:05", c[1]+"/"+c[2]+"/"+c[3]+" "+c[4], time.Local)
+	if p.OnRotate != nil {
+		p.OnRotate(created, line)
 	}
+	return created, true
+}
+
+func (p *PikaSlowLogItemParser) isCreatedLine(line string) bool {
+	return p.reCreated.MatchString(strings.TrimLeft(strings.TrimPrefix(line, "LOG:"), " "))
 }
 
 func (p *PikaSlowLogItemParser) isCommandHead(line string) bool {
@@ -654,6 +1043,11 @@ func (p *PikaSlowLogItemParser) isCommandHead(line string) bool {
 	if p.reStartSec.MatchString(s) && (strings.Contains(strings.ToLower(s), "command:") || strings.Contains(strings.ToLower(s), "cmd:")) {
 		return true
 	}
+	for _, re := range p.HeadPatterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
 	return false
 }
 
@@ -695,6 +1089,16 @@ func (p *PikaSlowLogItemParser) extractCommandAndStart(line string) (string, str
 			return cmd, st
 		}
 	}
+	// custom head patterns
+	for _, re := range p.HeadPatterns {
+		if m := re.FindStringSubmatch(s); len(m) >= 2 && m[1] != "" {
+			st := ""
+			if m2 := p.reStartSec.FindStringSubmatch(s); len(m2) == 2 {
+				st = m2[1]
+			}
+			return strings.ToUpper(strings.TrimSpace(m[1])), st
+		}
+	}
 	return "", ""
 }
 
@@ -713,6 +1117,7 @@ func (p *PikaSlowLogItemParser) nextLine() (string, bool) {
 		return s, true
 	}
 	if p.sc.Scan() {
+		p.progress.line(len(p.sc.Bytes()), p.ProgressEvery, p.OnProgress)
 		return p.sc.Text(), true
 	}
 	return "", false
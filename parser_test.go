@@ -0,0 +1,158 @@
+package logparser
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var seekBase = time.Date(2025, 11, 30, 3, 0, 0, 0, time.UTC)
+
+// writeSeekLOG writes a LOG of n items, item i headed at seekBase+i seconds; every 7th item
+// has continuation lines. At 40k items the file is several linear windows, so Seek bisects.
+func writeSeekLOG(t *testing.T, n int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "LOG")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	for i := 0; i < n; i++ {
+		ts := seekBase.Add(time.Duration(i) * time.Second).Format(DefaultTimeLayout)
+		fmt.Fprintf(w, "%s 7f1e2b7fe700 [db/db_impl/db_impl.cc:%d] item %d\n", ts, 1000+i%50, i)
+		if i%7 == 0 {
+			fmt.Fprintf(w, "  continuation %d of a multi-line item\n  and another\n", i)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// linearSeek is the reference Seek: the first head >= at found by scanning from the start.
+func linearSeek(t *testing.T, path string, at time.Time) (time.Time, bool) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if ht, ok := headTime(sc.Text()); ok && !ht.Before(at) {
+			return ht, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func checkSeek(t *testing.T, p *RocksDLogParser, path string, at time.Time) {
+	t.Helper()
+	want, ok := linearSeek(t, path, at)
+	err := p.Seek(at)
+	if !ok {
+		if !errors.Is(err, ErrEOF) {
+			t.Errorf("Seek(%v): err = %v, want ErrEOF", at, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("Seek(%v): %v, want item at %v", at, err, want)
+		return
+	}
+	it, err := p.Value()
+	if err != nil || !it.StartTime.Equal(want) {
+		t.Errorf("Seek(%v) = %v (%v), want %v", at, it.StartTime, err, want)
+	}
+}
+
+func TestRocksDSeekBisectMatchesLinearScan(t *testing.T) {
+	const n = 40000
+	path := writeSeekLOG(t, n)
+	if st, err := os.Stat(path); err != nil || st.Size() <= 2*seekLinearWindow {
+		t.Fatalf("fixture too small to bisect: %v %v", st, err)
+	}
+	sec := func(s int) time.Time { return seekBase.Add(time.Duration(s) * time.Second) }
+	targets := []time.Time{
+		{}, seekBase.Add(-time.Hour), sec(0), sec(10), sec(7),
+		sec(9999).Add(500 * time.Millisecond), sec(20000), sec(30000), sec(n - 2),
+		sec(n - 1), sec(n - 1).Add(time.Microsecond), sec(n + 100),
+	}
+	for _, at := range targets {
+		p, err := NewRocksDLogParser(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSeek(t, p, path, at)
+		p.Close()
+	}
+
+	// One parser reused: each Seek starts over, whatever was read before.
+	p, err := NewRocksDLogParser(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	for _, s := range []int{30000, 20000, 10, 30000, 0, n - 1, 5, 25000} {
+		checkSeek(t, p, path, sec(s))
+		for i := 0; i < 3 && p.Next(); i++ {
+		}
+	}
+	checkSeek(t, p, path, time.Time{})
+}
+
+func TestRocksDBisectLandsBeforeTarget(t *testing.T) {
+	path := writeSeekLOG(t, 40000)
+	p, err := NewRocksDLogParser(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	for _, s := range []int{10, 5000, 20000, 39990} {
+		at := seekBase.Add(time.Duration(s) * time.Second)
+		off := p.bisect(at)
+		if off < 0 {
+			t.Fatalf("bisect(%d) = %d", s, off)
+		}
+		// the first head past the offset is before at, and at most a window of ~50-byte items earlier
+		ht, ok, err := p.firstHeadAfter(off, off+2*seekLinearWindow)
+		if err != nil || !ok {
+			t.Fatalf("bisect(%d): no head after %d: %v", s, off, err)
+		}
+		if off > 0 && !ht.Before(at) {
+			t.Errorf("bisect(%d) = %d lands past the target (head %v)", s, off, ht)
+		}
+		if d := at.Sub(ht); d > time.Duration(2*seekLinearWindow/50)*time.Second {
+			t.Errorf("bisect(%d) = %d lands %v before the target", s, off, d)
+		}
+	}
+}
+
+func TestRocksDSeekLastHead(t *testing.T) {
+	path := writeSeekLOG(t, 20)
+	last := seekBase.Add(19 * time.Second)
+	p, err := NewRocksDLogParser(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	// the tail fast path must not report EOF for a target equal to the last head
+	if err := p.Seek(last); err != nil {
+		t.Fatalf("Seek(last head): %v", err)
+	}
+	if it, _ := p.Value(); !it.StartTime.Equal(last) {
+		t.Errorf("Seek(last head) = %v, want %v", it.StartTime, last)
+	}
+	if err := p.Seek(last.Add(time.Second)); !errors.Is(err, ErrEOF) {
+		t.Errorf("Seek(past last head): err = %v, want ErrEOF", err)
+	}
+}
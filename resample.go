@@ -0,0 +1,69 @@
+package logparser
+
+import (
+	"strings"
+	"time"
+)
+
+// Resample snaps every series (Name, SourceType, Labels) onto a shared time grid of the given step,
+// so that series sampled at slightly different times line up for ComputeExpression joins.
+// The grid is epoch-aligned (like BucketAggregator) and each series is emitted on the grid points
+// from its first bucket up to its last sample. Methods:
+//   - "hold":   last observed value at or before the grid point (the first sample for the first point)
+//   - "linear": linear interpolation between the surrounding samples
+//
+// Unknown methods fall back to "hold". A non-positive step returns the input unchanged.
+func Resample(metrics []Metric, step time.Duration, method string) []Metric {
+	if step <= 0 {
+		return metrics
+	}
+	linear := strings.ToLower(strings.TrimSpace(method)) == "linear"
+	type series struct {
+		pts []Metric
+	}
+	order := make([]string, 0)
+	seriesMap := make(map[string]*series)
+	for _, m := range metrics {
+		if m.StartTime.IsZero() {
+			continue
+		}
+		key := m.Name + "|" + string(m.SourceType) + "|" + labelsKey(m.Labels)
+		s := seriesMap[key]
+		if s == nil {
+			s = &series{}
+			seriesMap[key] = s
+			order = append(order, key)
+		}
+		s.pts = append(s.pts, m)
+	}
+	out := make([]Metric, 0, len(metrics))
+	for _, key := range order {
+		pts := seriesMap[key].pts
+		SortByTime(pts)
+		first, last := pts[0], pts[len(pts)-1]
+		idx := 0 // index of the latest sample with StartTime <= t
+		for t := alignToBucketStart(first.StartTime, step); !t.After(last.StartTime); t = t.Add(step) {
+			for idx+1 < len(pts) && !pts[idx+1].StartTime.After(t) {
+				idx++
+			}
+			prev := pts[idx]
+			val := prev.Value
+			if linear && prev.StartTime.Before(t) && idx+1 < len(pts) {
+				next := pts[idx+1]
+				span := next.StartTime.Sub(prev.StartTime).Seconds()
+				if span > 0 {
+					ratio := t.Sub(prev.StartTime).Seconds() / span
+					val = prev.Value + (next.Value-prev.Value)*ratio
+				}
+			}
+			out = append(out, Metric{
+				SourceType: first.SourceType,
+				StartTime:  t,
+				Name:       first.Name,
+				Value:      val,
+				Labels:     first.Labels,
+			})
+		}
+	}
+	return out
+}
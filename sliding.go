@@ -0,0 +1,94 @@
+package logparser
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// SlidingPercentile aggregates each series (Name, SourceType, Labels) into a percentile over a
+// sliding window: one output point per Step bucket (epoch-aligned, like BucketAggregator), whose
+// value is the Percentile of the samples in the Window ending at the bucket's end. A Window of
+// Step (or less) gives tumbling buckets; a larger Window overlaps and smooths the trend.
+//
+// Output names are "<Name>_P<Percentile>" (e.g. "DB_Get_P99_us_P95"). Unlike BucketAggregator's
+// single map pass, every series is sorted by time and each bucket sorts a copy of its window,
+// so the cost is O(n log n) per series plus O(buckets * w log w) for w samples per window;
+// memory holds one series and one window at a time beyond the output.
+type SlidingPercentile struct {
+	Step   time.Duration
+	Window time.Duration
+	// Percentile in (0, 100]; nearest-rank over the window's samples.
+	Percentile float64
+}
+
+func NewSlidingPercentile(step, window time.Duration, percentile float64) *SlidingPercentile {
+	return &SlidingPercentile{Step: step, Window: window, Percentile: percentile}
+}
+
+// Aggregate emits, per series, one point per bucket from the first sample's bucket to the last
+// sample's; buckets whose window holds no samples are skipped. A non-positive Step or a
+// Percentile outside (0, 100] returns nil.
+func (a *SlidingPercentile) Aggregate(metrics []Metric) []Metric {
+	if a.Step <= 0 || a.Percentile <= 0 || a.Percentile > 100 {
+		return nil
+	}
+	window := a.Window
+	if window < a.Step {
+		window = a.Step
+	}
+	suffix := fmt.Sprintf("_P%g", a.Percentile)
+	order := make([]string, 0)
+	seriesMap := make(map[string][]Metric)
+	for _, m := range metrics {
+		if m.StartTime.IsZero() {
+			continue
+		}
+		key := m.Name + "|" + string(m.SourceType) + "|" + labelsKey(m.Labels)
+		if _, ok := seriesMap[key]; !ok {
+			order = append(order, key)
+		}
+		seriesMap[key] = append(seriesMap[key], m)
+	}
+	var out []Metric
+	for _, key := range order {
+		pts := seriesMap[key]
+		SortByTime(pts)
+		first := pts[0].StartTime.Truncate(a.Step)
+		last := pts[len(pts)-1].StartTime
+		lo, hi := 0, 0
+		vals := make([]float64, 0, len(pts))
+		for bkt := first; !bkt.After(last); bkt = bkt.Add(a.Step) {
+			end := bkt.Add(a.Step)
+			from := end.Add(-window)
+			// window is [from, end): advance both edges over the sorted samples
+			for hi < len(pts) && pts[hi].StartTime.Before(end) {
+				hi++
+			}
+			for lo < hi && pts[lo].StartTime.Before(from) {
+				lo++
+			}
+			if lo == hi {
+				continue
+			}
+			vals = vals[:0]
+			for _, p := range pts[lo:hi] {
+				vals = append(vals, p.Value)
+			}
+			sort.Float64s(vals)
+			rank := int(math.Ceil(a.Percentile/100*float64(len(vals)))) - 1
+			if rank < 0 {
+				rank = 0
+			}
+			out = append(out, Metric{
+				SourceType: pts[0].SourceType,
+				StartTime:  bkt.Truncate(time.Second),
+				Name:       pts[0].Name + suffix,
+				Value:      vals[rank],
+				Labels:     pts[0].Labels,
+			})
+		}
+	}
+	return out
+}
@@ -1,7 +1,7 @@
 package logparser
 
 import (
-	"sort"
+	"strings"
 	"time"
 )
 
@@ -21,14 +21,120 @@ const (
 	// For each metric series (by Name and optionally SourceType), points are time-sorted and
 	// the first point has no increment (treated as 0). Subsequent points contribute their delta.
 	ModeDelta
+	// ModeCountDistinct counts the distinct values a metric took in each bucket.
+	ModeCountDistinct
+	// ModeSumRate sums the metric Value per bucket and divides by the bucket length in seconds,
+	// turning per-interval counts into a per-second rate.
+	ModeSumRate
 )
 
 // BucketAggregator aggregates metrics into fixed time-step buckets.
-// Grouping keys default to (Name, CF, SourceType). You can disable CF/SourceType grouping.
+// Grouping keys default to (Name, SourceType, Labels). You can disable SourceType grouping.
 type BucketAggregator struct {
-	Step           time.Duration
-	Mode           AggregateMode
-	GroupBySource  bool
+	Step time.Duration
+	Mode AggregateMode
+	// Deprecated: use GroupBy. Only consulted when GroupBy is empty.
+	GroupBySource bool
+	// GroupBy lists the dimensions that define a series besides Name: "source" for SourceType
+	// and any label key (e.g. "cf", "level"). Dimensions not listed are collapsed and dropped
+	// from the output labels. Empty keeps the legacy grouping (all labels, GroupBySource).
+	GroupBy []string
+	// ProrateDelta (ModeDelta only) splits each increment across the buckets between the two
+	// sample times, proportionally to the time each bucket covers, instead of attributing it
+	// all to the bucket of the later point.
+	ProrateDelta bool
+	// AlignToFirst starts buckets at the earliest StartTime in the input (every Step from
+	// there) instead of at epoch-aligned boundaries, so a chart begins at the data edge.
+	AlignToFirst bool
+	// Offset shifts epoch-aligned buckets by a fixed phase, e.g. 7m makes 10m buckets start
+	// at :07, :17, ... Zero keeps epoch alignment; ignored with AlignToFirst.
+	Offset time.Duration
+	// Label picks the time each aggregated point is stamped with: "start" (default) of its
+	// bucket, "center" (start + Step/2, so rates don't visually lag) or "end" (start + Step,
+	// for joining with systems that label buckets by their end).
+	Label string
+}
+
+// stamp returns the output time of the bucket starting at bkt, per Label.
+func (a *BucketAggregator) stamp(bkt time.Time) time.Time {
+	switch strings.ToLower(strings.TrimSpace(a.Label)) {
+	case "center", "centre", "mid", "middle":
+		return bkt.Add(a.Step / 2).Truncate(time.Second)
+	case "end":
+		return bkt.Add(a.Step)
+	default:
+		return bkt
+	}
+}
+
+// bucketGrid places bucket boundaries: every step from origin when set, else every step
+// from the epoch shifted by offset.
+type bucketGrid struct {
+	origin time.Time
+	offset time.Duration
+	step   time.Duration
+}
+
+// grid returns the bucket grid for metrics: anchored at the earliest StartTime when
+// AlignToFirst is set, else epoch-aligned with Offset.
+func (a *BucketAggregator) grid(metrics []Metric) bucketGrid {
+	g := bucketGrid{offset: a.Offset, step: a.Step}
+	if !a.AlignToFirst {
+		return g
+	}
+	for _, m := range metrics {
+		if !m.StartTime.IsZero() && (g.origin.IsZero() || m.StartTime.Before(g.origin)) {
+			g.origin = m.StartTime
+		}
+	}
+	return g
+}
+
+// floor returns the start of the bucket holding t.
+func (g bucketGrid) floor(t time.Time) time.Time {
+	if !g.origin.IsZero() && !t.Before(g.origin) {
+		return g.origin.Add(t.Sub(g.origin) / g.step * g.step)
+	}
+	return t.Add(-g.offset).Truncate(g.step).Add(g.offset)
+}
+
+// align is alignToBucketStart on the grid.
+func (g bucketGrid) align(t time.Time) time.Time {
+	if g.step <= 0 || (g.origin.IsZero() && g.offset == 0) {
+		return alignToBucketStart(t, g.step)
+	}
+	return g.floor(t).Truncate(time.Second)
+}
+
+// seriesOf returns the grouping key of in (without the bucket) plus the SourceType and labels
+// the aggregated output should carry.
+func (a *BucketAggregator) seriesOf(in Metric) (string, LogType, map[string]string) {
+	source := LogTypeOther
+	labels := in.Labels
+	if len(a.GroupBy) == 0 {
+		if a.GroupBySource {
+			source = in.SourceType
+		}
+	} else {
+		labels = nil
+		for _, k := range a.GroupBy {
+			k = strings.TrimSpace(k)
+			switch strings.ToLower(k) {
+			case "", "name":
+				// Name is always part of the key
+			case "source", "sourcetype":
+				source = in.SourceType
+			default:
+				if v, ok := in.Labels[k]; ok {
+					if labels == nil {
+						labels = make(map[string]string, len(a.GroupBy))
+					}
+					labels[k] = v
+				}
+			}
+		}
+	}
+	return in.Name + "|" + string(source) + "|" + labelsKey(labels), source, labels
 }
 
 func NewBucketAggregator(step time.Duration, mode AggregateMode) *BucketAggregator {
@@ -40,54 +146,64 @@ func NewBucketAggregator(step time.Duration, mode AggregateMode) *BucketAggregat
 }
 
 // Aggregate reduces the provided metrics into buckets and returns aggregated metrics.
-// - Time is set to the bucket start (second precision).
+// - Time is set to the bucket start (second precision), or its center or end per Label.
 // - Name uses a suffix when needed:
 //   - ModeCount: "<Name>_Count"
 //   - ModeSum:   "<Name>_Sum"
 //   - ModeFirst: "<Name>_First"
 //   - ModeAvg:   "<Name>_Avg"
-// - CF/SourceType grouping depends on the aggregator flags.
+//   - ModeCountDistinct: "<Name>_DistinctCount"
+//   - ModeSumRate: "<Name>_PerSec"
+// - Series grouping depends on GroupBy (or the legacy GroupBySource flag).
 func (a *BucketAggregator) Aggregate(metrics []Metric) []Metric {
+	grid := a.grid(metrics)
 	// Special handling for delta aggregation: we must respect temporal order
 	// within each metric series to compute increments.
 	if a.Mode == ModeDelta {
-		// Group by series key (Name + optional SourceType)
+		// Group by series key (Name + GroupBy dimensions)
 		type series struct {
-			st   LogType
-			name string
-			pts  []Metric
+			st     LogType
+			name   string
+			labels map[string]string
+			pts    []Metric
 		}
 		seriesMap := make(map[string]*series, len(metrics))
 		for _, in := range metrics {
 			if in.StartTime.IsZero() {
 				continue
 			}
-			name := in.Name
-			source := LogTypeOther
-			if a.GroupBySource {
-				source = in.SourceType
-			}
-			key := name + "|" + string(source)
+			key, source, labels := a.seriesOf(in)
 			s := seriesMap[key]
 			if s == nil {
-				s = &series{st: source, name: name, pts: make([]Metric, 0, 32)}
+				s = &series{st: source, name: in.Name, labels: labels, pts: make([]Metric, 0, 32)}
 				seriesMap[key] = s
 			}
 			s.pts = append(s.pts, in)
 		}
 		// Accumulate deltas into buckets
 		type acc struct {
-			sum float64
-			st  LogType
-			bkt time.Time
-			nm  string
+			sum    float64
+			st     LogType
+			bkt    time.Time
+			nm     string
+			labels map[string]string
 		}
 		buckets := make(map[string]*acc, len(metrics))
-		for _, s := range seriesMap {
+		for sk, s := range seriesMap {
 			// sort by time
-			sort.Slice(s.pts, func(i, j int) bool { return s.pts[i].StartTime.Before(s.pts[j].StartTime) })
+			SortByTime(s.pts)
 			prevSet := false
 			var prev float64
+			var prevTime time.Time
+			addTo := func(bkt time.Time, v float64) {
+				key := bkt.Format("2006/01/02-15:04:05") + "|" + sk
+				ac := buckets[key]
+				if ac == nil {
+					ac = &acc{sum: 0, st: s.st, bkt: bkt, nm: s.name, labels: s.labels}
+					buckets[key] = ac
+				}
+				ac.sum += v
+			}
 			for _, p := range s.pts {
 				var delta float64
 				if prevSet {
@@ -96,61 +212,65 @@ func (a *BucketAggregator) Aggregate(metrics []Metric) []Metric {
 					delta = 0
 					prevSet = true
 				}
-				prev = p.Value
-				bkt := alignToBucketStart(p.StartTime, a.Step)
-				key := bkt.Format("2006/01/02-15:04:05") + "|" + s.name + "|" + string(s.st)
-				ac := buckets[key]
-				if ac == nil {
-					ac = &acc{sum: 0, st: s.st, bkt: bkt, nm: s.name}
-					buckets[key] = ac
+				if a.ProrateDelta && a.Step > 0 && !prevTime.IsZero() && p.StartTime.After(prevTime) {
+					prorateDelta(prevTime, p.StartTime, delta, grid, addTo)
+				} else {
+					addTo(grid.align(p.StartTime), delta)
 				}
-				ac.sum += delta
+				prev = p.Value
+				prevTime = p.StartTime
 			}
 		}
 		out := make([]Metric, 0, len(buckets))
 		for _, ac := range buckets {
 			out = append(out, Metric{
 				SourceType: ac.st,
-				StartTime:  ac.bkt,
+				StartTime:  a.stamp(ac.bkt),
 				Name:       ac.nm + "_Delta",
 				Value:      ac.sum,
+				Labels:     ac.labels,
 			})
 		}
 		return out
 	}
 
 	type acc struct {
-		sum   float64
-		count float64
-		name  string
-		st    LogType
-		bkt   time.Time
+		sum    float64
+		count  float64
+		name   string
+		st     LogType
+		bkt    time.Time
+		labels map[string]string
 		// track earliest value for ModeFirst
 		firstVal  float64
 		firstTime time.Time
 		firstSet  bool
+		// distinct values, only tracked for ModeCountDistinct
+		distinct map[float64]struct{}
 	}
-	// bucket key: ts|name|source
+	// bucket key: ts|name|source|labels
 	m := make(map[string]*acc, len(metrics))
 
 	for _, in := range metrics {
 		if in.StartTime.IsZero() {
 			continue
 		}
-		bkt := alignToBucketStart(in.StartTime, a.Step)
-		name := in.Name
-		source := LogTypeOther
-		if a.GroupBySource {
-			source = in.SourceType
-		}
-		key := bkt.Format("2006/01/02-15:04:05") + "|" + name + "|" + string(source)
+		bkt := grid.align(in.StartTime)
+		sk, source, labels := a.seriesOf(in)
+		key := bkt.Format("2006/01/02-15:04:05") + "|" + sk
 		ac := m[key]
 		if ac == nil {
-			ac = &acc{name: name, st: source, bkt: bkt}
+			ac = &acc{name: in.Name, st: source, bkt: bkt, labels: labels}
 			m[key] = ac
 		}
 		ac.count += 1
 		ac.sum += in.Value
+		if a.Mode == ModeCountDistinct {
+			if ac.distinct == nil {
+				ac.distinct = make(map[float64]struct{})
+			}
+			ac.distinct[in.Value] = struct{}{}
+		}
 		// track earliest
 		if !ac.firstSet || in.StartTime.Before(ac.firstTime) {
 			ac.firstSet = true
@@ -181,20 +301,45 @@ func (a *BucketAggregator) Aggregate(metrics []Metric) []Metric {
 				val = 0
 			}
 			outName = ac.name + "_Avg"
+		case ModeCountDistinct:
+			val = float64(len(ac.distinct))
+			outName = ac.name + "_DistinctCount"
+		case ModeSumRate:
+			val = ac.sum
+			if a.Step > 0 {
+				val /= a.Step.Seconds()
+			}
+			outName = ac.name + "_PerSec"
 		default:
 			val = ac.sum
 			outName = ac.name + "_Sum"
 		}
 		out = append(out, Metric{
 			SourceType: ac.st,
-			StartTime:  ac.bkt,
+			StartTime:  a.stamp(ac.bkt),
 			Name:       outName,
 			Value:      val,
+			Labels:     ac.labels,
 		})
 	}
 	return out
 }
 
+// prorateDelta splits delta, accrued over (from, to], across the step buckets the interval
+// spans, giving each bucket a share proportional to the time it covers.
+func prorateDelta(from, to time.Time, delta float64, grid bucketGrid, add func(bkt time.Time, v float64)) {
+	total := to.Sub(from)
+	for cur := from; cur.Before(to); {
+		bkt := grid.floor(cur)
+		next := bkt.Add(grid.step)
+		if next.After(to) {
+			next = to
+		}
+		add(bkt.Truncate(time.Second), delta*float64(next.Sub(cur))/float64(total))
+		cur = next
+	}
+}
+
 func alignToBucketStart(t time.Time, step time.Duration) time.Time {
 	if step <= 0 {
 		return t.Truncate(time.Second)
@@ -0,0 +1,100 @@
+package logparser
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// TopNByPeak keeps the metrics of the n series (by Name) with the highest maximum value.
+func TopNByPeak(metrics []Metric, n int) []Metric {
+	return topN(metrics, n, false)
+}
+
+// TopNByTotal keeps the metrics of the n series (by Name) with the highest sum of values.
+func TopNByTotal(metrics []Metric, n int) []Metric {
+	return topN(metrics, n, true)
+}
+
+// OtherSeries sums, per StartTime, the metrics of all that are not in kept (by Name) into one
+// series called name, so a Top-N chart can still show the remainder.
+func OtherSeries(all, kept []Metric, name string) []Metric {
+	keep := make(map[string]struct{})
+	for _, m := range kept {
+		keep[m.Name] = struct{}{}
+	}
+	sums := make(map[time.Time]float64)
+	src := make(map[time.Time]LogType)
+	for _, m := range all {
+		if _, ok := keep[m.Name]; ok || m.StartTime.IsZero() {
+			continue
+		}
+		sums[m.StartTime] += m.Value
+		src[m.StartTime] = m.SourceType
+	}
+	out := make([]Metric, 0, len(sums))
+	for t, v := range sums {
+		out = append(out, Metric{SourceType: src[t], StartTime: t, Name: name, Value: v})
+	}
+	SortByTime(out)
+	return out
+}
+
+func topN(metrics []Metric, n int, byTotal bool) []Metric {
+	if n <= 0 {
+		return metrics
+	}
+	score := make(map[string]float64)
+	for _, m := range metrics {
+		s, ok := score[m.Name]
+		switch {
+		case byTotal:
+			score[m.Name] = s + m.Value
+		case !ok || m.Value > s:
+			score[m.Name] = m.Value
+		}
+	}
+	if len(score) <= n {
+		return metrics
+	}
+	names := make([]string, 0, len(score))
+	for name := range score {
+		names = append(names, name)
+	}
+	// ties broken by name for a stable selection
+	sort.Slice(names, func(i, j int) bool {
+		if score[names[i]] != score[names[j]] {
+			return score[names[i]] > score[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	keep := make(map[string]struct{}, n)
+	for _, name := range names[:n] {
+		keep[name] = struct{}{}
+	}
+	out := make([]Metric, 0, len(metrics))
+	for _, m := range metrics {
+		if _, ok := keep[m.Name]; ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// applyTopN limits a group's selection to its TopN series (ranked per TopBy) and optionally
+// appends an "Other" series summing the rest. TopN <= 0 keeps the selection unchanged.
+func applyTopN(g ChartGroup, selected []Metric) []Metric {
+	if g.TopN <= 0 {
+		return selected
+	}
+	var kept []Metric
+	if strings.EqualFold(strings.TrimSpace(g.TopBy), "total") {
+		kept = TopNByTotal(selected, g.TopN)
+	} else {
+		kept = TopNByPeak(selected, g.TopN)
+	}
+	if g.TopOther && len(kept) < len(selected) {
+		kept = append(kept, OtherSeries(selected, kept, "Other")...)
+	}
+	return kept
+}